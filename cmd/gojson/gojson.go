@@ -1,9 +1,15 @@
 package main
 
-import "github.com/x0y14/gojson/gojson"
+import (
+	"log"
+
+	"github.com/x0y14/gojson/gojson"
+)
 
 func main() {
 	json := "{\"msg\": \"hello\"}"
 	tk := gojson.NewTokenizer(json)
-	tk.Tokenize()
+	if _, err := tk.Tokenize(); err != nil {
+		log.Fatal(gojson.FormatError([]byte(json), err))
+	}
 }
@@ -0,0 +1,138 @@
+package gojson
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"unicode/utf8"
+)
+
+// Compact returns the document as JSON without whitespace and comments.
+// JSON5 is written as JSON: strings in double quotes, identifier keys quoted and
+// numbers in decimal. Infinity and NaN, which JSON has no form for, are an error.
+func (j *Json) Compact() ([]byte, error) {
+	return j.AppendCompact(nil)
+}
+
+// AppendCompact appends the document as written by Compact to dst.
+func (j *Json) AppendCompact(dst []byte) ([]byte, error) {
+	return appendCompact(dst, j.node)
+}
+
+func appendCompact(dst []byte, nd *Node) ([]byte, error) {
+	var err error
+	switch nd.Type {
+	case NDObject:
+		dst = append(dst, '{')
+		for i := range *nd.Children {
+			pair := &(*nd.Children)[i]
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			dst = appendQuoted(dst, pair.Key)
+			dst = append(dst, ':')
+			if dst, err = appendCompact(dst, &(*pair.Children)[0]); err != nil {
+				return nil, err
+			}
+		}
+		return append(dst, '}'), nil
+	case NDArray:
+		dst = append(dst, '[')
+		for i := range *nd.Children {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			if dst, err = appendCompact(dst, &(*nd.Children)[i]); err != nil {
+				return nil, err
+			}
+		}
+		return append(dst, ']'), nil
+	default:
+		return appendToken(dst, nd.Val)
+	}
+}
+
+func appendToken(dst []byte, token *Token) ([]byte, error) {
+	switch token.Type {
+	case TTrue:
+		return append(dst, "true"...), nil
+	case TFalse:
+		return append(dst, "false"...), nil
+	case TNull:
+		return append(dst, "null"...), nil
+	case TString:
+		s, err := token.LoadAsString()
+		if err != nil {
+			return nil, err
+		}
+		return appendQuoted(dst, s), nil
+	case TNumber:
+		// a number that is JSON already is kept as written
+		raw := token.Raw()
+		tk := Tokenizer{Source: raw}
+		if len(raw) > 0 && tk.scanNumber() == "" && tk.Pos == len(raw) {
+			return append(dst, raw...), nil
+		}
+		f, err := token.LoadAsFloat64()
+		if err != nil {
+			return nil, err
+		}
+		if math.IsInf(f, 0) || math.IsNaN(f) {
+			return nil, token.loadError(fmt.Sprintf("%v can not be written as JSON", string(raw)))
+		}
+		return strconv.AppendFloat(dst, f, 'g', -1, 64), nil
+	default:
+		return nil, token.loadError("This Token is not a value")
+	}
+}
+
+const hexDigits = "0123456789abcdef"
+
+// appendQuoted appends s as a JSON string. Surrogate code points kept by
+// LoneSurrogateKeep are escaped, other invalid UTF-8 becomes U+FFFD.
+func appendQuoted(dst []byte, s string) []byte {
+	dst = append(dst, '"')
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c < utf8.RuneSelf {
+			switch {
+			case c == '"' || c == '\\':
+				dst = append(dst, '\\', c)
+			case c == '\n':
+				dst = append(dst, '\\', 'n')
+			case c == '\r':
+				dst = append(dst, '\\', 'r')
+			case c == '\t':
+				dst = append(dst, '\\', 't')
+			case c < 0x20:
+				dst = append(dst, '\\', 'u', '0', '0', hexDigits[c>>4], hexDigits[c&0xF])
+			default:
+				dst = append(dst, c)
+			}
+			i++
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == utf8.RuneError && size == 1 && isSurrogateBytes(s[i:]):
+			r = rune(s[i]&0x0F)<<12 | rune(s[i+1]&0x3F)<<6 | rune(s[i+2]&0x3F)
+			dst = append(dst, '\\', 'u', hexDigits[r>>12], hexDigits[r>>8&0xF], hexDigits[r>>4&0xF], hexDigits[r&0xF])
+			size = 3
+		case r == utf8.RuneError && size == 1:
+			dst = append(dst, "\ufffd"...)
+		case r == '\u2028' || r == '\u2029':
+			// valid JSON, but not JavaScript before ES2019
+			dst = append(dst, '\\', 'u', '2', '0', '2', hexDigits[r&0xF])
+		default:
+			dst = append(dst, s[i:i+size]...)
+		}
+		i += size
+	}
+	return append(dst, '"')
+}
+
+// isSurrogateBytes reports whether s starts with a surrogate code point as written by appendRune.
+func isSurrogateBytes(s string) bool {
+	return len(s) >= 3 && s[0] == 0xED && s[1] >= 0xA0 && s[1] <= 0xBF && s[2]&0xC0 == 0x80
+}
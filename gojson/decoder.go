@@ -0,0 +1,163 @@
+package gojson
+
+import (
+	"bytes"
+	"io"
+)
+
+// Decoder reads JSON values that follow one another in a stream, such as
+// {..}{..}[..] with any whitespace in between, one value per Decode.
+// A malformed value is reported by its Decode and skipped, so that the
+// values behind it can still be read.
+type Decoder struct {
+	// SourceName is the file name or the like reported in errors
+	SourceName       string
+	TokenizerOptions TokenizerOptions
+	Options          ParserOptions
+
+	tk *StreamTokenizer
+	ps *Parser
+	// errs are the errors of the malformed tokens pulled but not reported yet
+	errs []*TokenizerError
+	// offset is the end of the last value decoded or skipped
+	offset int
+}
+
+func NewDecoder(r io.Reader) *Decoder {
+	d := &Decoder{tk: NewStreamTokenizer(r)}
+	d.ps = NewStreamParser(decoderSource{d})
+	return d
+}
+
+// decoderSource hands the tokens of the stream to the parser. Unlike the
+// StreamTokenizer itself, it carries on past a malformed token, which becomes
+// TUnknown, and only fails with the reader.
+type decoderSource struct {
+	d *Decoder
+}
+
+func (s decoderSource) Next() (Token, error) {
+	d := s.d
+	d.tk.Options = d.TokenizerOptions
+	d.tk.SourceName = d.SourceName
+	token, err := d.tk.Next()
+	tkErr, ok := err.(*TokenizerError)
+	if !ok || tkErr.ErrorType == TokenTooLongError {
+		return token, err
+	}
+	d.errs = append(d.errs, tkErr)
+	if tkErr.ErrorType == InvalidEscapeError && token.Type == TString {
+		d.tk.skipString(token)
+	}
+	token.Type = TUnknown
+	return token, nil
+}
+
+// More reports whether there is another value in the stream.
+func (d *Decoder) More() bool {
+	return d.ps.Token().Type != TEof
+}
+
+// InputOffset returns the offset in the stream just behind the last value
+// decoded or skipped, counted like the positions of a StreamTokenizer.
+func (d *Decoder) InputOffset() int64 {
+	return int64(d.offset)
+}
+
+// Buffered returns the data the Decoder has read from the reader beyond InputOffset.
+// The Decoder reads ahead in blocks, so this and what is left in the reader make up
+// the rest of the stream, to be handed on to other code after the last value.
+// Input in UTF-16 or UTF-32 is buffered as UTF-8. The reader is valid until the next
+// call to Decode or More.
+func (d *Decoder) Buffered() io.Reader {
+	return bytes.NewReader(d.tk.buffered(d.offset))
+}
+
+// Decode reads the next value. It returns io.EOF at the end of the stream,
+// and the first error of a malformed value, having skipped it.
+// Once the reader fails, its error is returned.
+func (d *Decoder) Decode() (*Json, error) {
+	p := d.ps
+	p.Options = d.Options
+	p.SourceName = d.SourceName
+	p.Depth, p.nodes, p.path = 0, 0, p.path[:0]
+	p.diagnostics, p.aborted = nil, false
+
+	start := p.Token()
+	if p.sourceErr != nil && (start.Type == TUnknown || start.Type == TEof) {
+		return nil, p.sourceErr
+	}
+	if start.Type == TEof {
+		return nil, io.EOF
+	}
+
+	var nd *Node
+	if startsValue(start.Type) {
+		// a closing bracket at the top ends the value even if it is the wrong one,
+		// rather than the values behind it getting read into it
+		p.closers = append(p.closers[:0], TRCurlyBracket, TRSquareBracket)
+		nd = p.recoverValue()
+		p.closers = p.closers[:0]
+	} else {
+		_, err := p.ParseValue()
+		p.report(SeverityError, err.(*ParserError))
+	}
+
+	err := d.documentError()
+	if err != nil {
+		// skip whatever is left of the malformed value
+		if p.aborted {
+			// the brackets open when a limit was hit, less the two of the top
+			for open := p.abortedAt - 2; open > 0 && p.Token().Type != TEof; p.GoNext() {
+				switch p.Token().Type {
+				case TLCurlyBracket, TLSquareBracket:
+					open++
+				case TRCurlyBracket, TRSquareBracket:
+					open--
+				}
+			}
+		}
+		for tk := p.Token(); !startsValue(tk.Type) && tk.Type != TEof; tk = p.Token() {
+			p.GoNext()
+		}
+		d.offset = p.PrevToken().End
+		d.dropErrors()
+		if p.sourceErr != nil {
+			return nil, p.sourceErr
+		}
+		return nil, err
+	}
+	d.offset = p.PrevToken().End
+	d.dropErrors()
+	return NewJson(nd, nd.Type), nil
+}
+
+// documentError returns the first error of the value just parsed, which is that
+// of a malformed token if the parser stumbled on it, or nil.
+func (d *Decoder) documentError() error {
+	var psErr *ParserError
+	for _, diagnostic := range d.ps.diagnostics {
+		if diagnostic.Severity == SeverityError {
+			psErr = diagnostic.err
+			break
+		}
+	}
+	d.ps.diagnostics = nil
+	if psErr == nil {
+		return nil
+	}
+	if len(d.errs) > 0 && (psErr.FoundType == TUnknown || d.errs[0].Start.Offset <= psErr.Start.Offset) {
+		return d.errs[0]
+	}
+	psErr.SourceName = d.SourceName
+	return psErr
+}
+
+// dropErrors forgets the errors of the malformed tokens up to offset.
+func (d *Decoder) dropErrors() {
+	i := 0
+	for i < len(d.errs) && d.errs[i].Start.Offset < d.offset {
+		i++
+	}
+	d.errs = d.errs[i:]
+}
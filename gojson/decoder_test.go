@@ -0,0 +1,185 @@
+package gojson
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// decodeAll decodes every value of json, writing down the values and the types of the errors.
+func decodeAll(t *testing.T, d *Decoder) ([]interface{}, []ErrorType) {
+	var values []interface{}
+	var errorTypes []ErrorType
+	for i := 0; d.More(); i++ {
+		if i > 100 {
+			t.Fatal("the decoder does not move on")
+		}
+		js, err := d.Decode()
+		switch e := err.(type) {
+		case nil:
+			value, _ := js.Value()
+			values = append(values, value)
+		case *TokenizerError:
+			errorTypes = append(errorTypes, e.ErrorType)
+		case *ParserError:
+			errorTypes = append(errorTypes, e.ErrorType)
+		default:
+			t.Fatal(err)
+		}
+	}
+	_, err := d.Decode()
+	assert.Equal(t, io.EOF, err)
+	return values, errorTypes
+}
+
+func TestDecoder(t *testing.T) {
+	json := "{\"a\": 1}{\"b\": [true]}\n[1, 2]  \"x\"\t12 null\n\n[]"
+	d := NewDecoder(iotest.OneByteReader(strings.NewReader(json)))
+
+	var offsets []int64
+	for d.More() {
+		_, err := d.Decode()
+		assert.NoError(t, err)
+		offsets = append(offsets, d.InputOffset())
+	}
+	assert.Equal(t, []int64{8, 21, 28, 33, 36, 41, 45}, offsets)
+
+	values, errorTypes := decodeAll(t, NewDecoder(strings.NewReader(json)))
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"a": float64(1)},
+		map[string]interface{}{"b": []interface{}{true}},
+		[]interface{}{float64(1), float64(2)},
+		"x", float64(12), nil, []interface{}(nil),
+	}, values)
+	assert.Nil(t, errorTypes)
+}
+
+func TestDecoder_Errors(t *testing.T) {
+	var tests = []struct {
+		title      string
+		json       string
+		values     []interface{}
+		errorTypes []ErrorType
+	}{
+		{
+			"bad keyword",
+			`{"a": 1} {"b": tru} {"c": 3}`,
+			[]interface{}{map[string]interface{}{"a": float64(1)}, map[string]interface{}{"c": float64(3)}},
+			[]ErrorType{UndefinedKeywordError},
+		},
+		{
+			"invalid escape",
+			`["x\q {", "}"] [2]`,
+			[]interface{}{[]interface{}{float64(2)}},
+			[]ErrorType{InvalidEscapeError},
+		},
+		{
+			"invalid escape at the top",
+			`"a\qb" "c"`,
+			[]interface{}{"c"},
+			[]ErrorType{InvalidEscapeError},
+		},
+		{
+			"only an invalid escape",
+			`"\q"`,
+			nil,
+			[]ErrorType{InvalidEscapeError},
+		},
+		{
+			"missing comma",
+			`[1 2] [3]`,
+			[]interface{}{[]interface{}{float64(3)}},
+			[]ErrorType{SyntaxError},
+		},
+		{
+			"wrong closing bracket",
+			`{"a": [1} {"b": 2}`,
+			[]interface{}{map[string]interface{}{"b": float64(2)}},
+			[]ErrorType{SyntaxError},
+		},
+		{
+			"stray tokens",
+			`: ] {"a": 1}, [2]`,
+			[]interface{}{map[string]interface{}{"a": float64(1)}, []interface{}{float64(2)}},
+			[]ErrorType{SyntaxError, SyntaxError},
+		},
+		{
+			"too deep",
+			`[[[[1]], [2]]] [3]`,
+			[]interface{}{[]interface{}{float64(3)}},
+			[]ErrorType{DepthLimitError},
+		},
+		{
+			"unclosed",
+			`[1] {"a": [1`,
+			[]interface{}{[]interface{}{float64(1)}},
+			[]ErrorType{UnexpectedEndOfInputError},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			d := NewDecoder(strings.NewReader(tt.json))
+			d.Options.MaxDepth = 2
+			values, errorTypes := decodeAll(t, d)
+			assert.Equal(t, tt.values, values)
+			assert.Equal(t, tt.errorTypes, errorTypes)
+		})
+	}
+
+	// a JSON5 identifier with an invalid escape is not a string to skip
+	json := `{ab\x: 1} {"c": 2} [3]`
+	for _, r := range []io.Reader{strings.NewReader(json), iotest.OneByteReader(strings.NewReader(json))} {
+		d := NewDecoder(r)
+		d.TokenizerOptions.Dialect = DialectJSON5
+		values, errorTypes := decodeAll(t, d)
+		assert.Equal(t, []interface{}{map[string]interface{}{"c": float64(2)}, []interface{}{float64(3)}}, values)
+		assert.Equal(t, []ErrorType{InvalidEscapeError}, errorTypes)
+	}
+}
+
+func TestDecoder_ReaderError(t *testing.T) {
+	boom := errors.New("boom")
+	d := NewDecoder(io.MultiReader(strings.NewReader(`{"a": 1} [1, `), iotest.ErrReader(boom)))
+	_, err := d.Decode()
+	assert.NoError(t, err)
+	_, err = d.Decode()
+	assert.Equal(t, boom, err)
+	_, err = d.Decode()
+	assert.Equal(t, boom, err)
+}
+
+func TestDecoder_Buffered(t *testing.T) {
+	rest := strings.Repeat("x", 2*DefaultStreamBufferSize)
+	json := `{"a": 1}  [2]` + rest
+	src := strings.NewReader(json)
+	d := NewDecoder(src)
+	assert.Equal(t, "", readAll(t, d.Buffered()))
+
+	_, err := d.Decode()
+	assert.NoError(t, err)
+	_, err = d.Decode()
+	assert.NoError(t, err)
+	// what is buffered and what is left in the reader make up the rest of the stream
+	r := io.MultiReader(d.Buffered(), src)
+	assert.Equal(t, json[d.InputOffset():], readAll(t, r))
+
+	json = `[1] "two"  {"three": 3}`
+	src = strings.NewReader(json)
+	d = NewDecoder(iotest.OneByteReader(src))
+	for d.More() {
+		_, err := d.Decode()
+		assert.NoError(t, err)
+		buffered := readAll(t, d.Buffered())
+		assert.Equal(t, json[d.InputOffset():], buffered+json[len(json)-src.Len():])
+	}
+}
+
+func readAll(t *testing.T, r io.Reader) string {
+	b, err := io.ReadAll(r)
+	assert.NoError(t, err)
+	return string(b)
+}
@@ -0,0 +1,174 @@
+package gojson
+
+import (
+	"encoding/binary"
+	"io"
+	"unicode"
+	"unicode/utf16"
+)
+
+// Encoding is the character encoding of an input.
+type Encoding int
+
+const (
+	EncodingUTF8 Encoding = iota
+	EncodingUTF16BE
+	EncodingUTF16LE
+	EncodingUTF32BE
+	EncodingUTF32LE
+)
+
+func (enc Encoding) String() string {
+	switch enc {
+	case EncodingUTF16BE:
+		return "UTF-16BE"
+	case EncodingUTF16LE:
+		return "UTF-16LE"
+	case EncodingUTF32BE:
+		return "UTF-32BE"
+	case EncodingUTF32LE:
+		return "UTF-32LE"
+	default:
+		return "UTF-8"
+	}
+}
+
+// DetectEncoding guesses the encoding of an input from its first four bytes.
+// A byte order mark decides it, and bomSize is its length. Without one, the
+// pattern of null bytes is used as described in RFC 4627 section 3, since
+// the first two characters of a JSON text are ASCII:
+//
+//	00 00 00 xx  UTF-32BE
+//	00 xx 00 xx  UTF-16BE
+//	xx 00 00 00  UTF-32LE
+//	xx 00 xx 00  UTF-16LE
+//	xx xx xx xx  UTF-8
+func DetectEncoding(head []byte) (enc Encoding, bomSize int) {
+	b := func(i int) int {
+		if i < len(head) {
+			return int(head[i])
+		}
+		return -1
+	}
+
+	switch {
+	case b(0) == 0xEF && b(1) == 0xBB && b(2) == 0xBF:
+		return EncodingUTF8, 3
+	case b(0) == 0x00 && b(1) == 0x00 && b(2) == 0xFE && b(3) == 0xFF:
+		return EncodingUTF32BE, 4
+	case b(0) == 0xFF && b(1) == 0xFE && b(2) == 0x00 && b(3) == 0x00:
+		return EncodingUTF32LE, 4
+	case b(0) == 0xFE && b(1) == 0xFF:
+		return EncodingUTF16BE, 2
+	case b(0) == 0xFF && b(1) == 0xFE:
+		return EncodingUTF16LE, 2
+	}
+
+	switch {
+	case len(head) >= 4 && b(0) == 0 && b(1) == 0 && b(2) == 0:
+		return EncodingUTF32BE, 0
+	case len(head) >= 4 && b(1) == 0 && b(2) == 0 && b(3) == 0:
+		return EncodingUTF32LE, 0
+	case len(head) >= 2 && b(0) == 0:
+		return EncodingUTF16BE, 0
+	case len(head) >= 2 && b(1) == 0:
+		return EncodingUTF16LE, 0
+	default:
+		return EncodingUTF8, 0
+	}
+}
+
+// invalidByte is written out for a malformed UTF-16 or UTF-32 sequence.
+// It can never be part of UTF-8, so the tokenizer rejects it where it stands.
+const invalidByte = 0xFF
+
+// transcode appends the UTF-8 form of the UTF-16 or UTF-32 src to dst and
+// returns how many bytes of src it used. Unless final is set, it stops in
+// front of an incomplete code unit or surrogate pair at the end of src.
+func transcode(dst []byte, src []byte, enc Encoding, final bool) ([]byte, int) {
+	var order binary.ByteOrder = binary.BigEndian
+	if enc == EncodingUTF16LE || enc == EncodingUTF32LE {
+		order = binary.LittleEndian
+	}
+
+	i := 0
+	if enc == EncodingUTF32BE || enc == EncodingUTF32LE {
+		for ; i+4 <= len(src); i += 4 {
+			r := rune(order.Uint32(src[i:]))
+			if r < 0 || r > unicode.MaxRune || utf16.IsSurrogate(r) {
+				dst = append(dst, invalidByte)
+				continue
+			}
+			dst = appendRune(dst, r)
+		}
+	} else {
+		for ; i+2 <= len(src); i += 2 {
+			r := rune(order.Uint16(src[i:]))
+			switch {
+			case !utf16.IsSurrogate(r):
+				dst = appendRune(dst, r)
+			case r >= 0xDC00:
+				dst = append(dst, invalidByte)
+			case i+4 > len(src) && !final:
+				// the low surrogate has not been read yet
+				return dst, i
+			case i+4 > len(src):
+				dst = append(dst, invalidByte)
+			default:
+				// a valid pair never decodes to U+FFFD
+				if pair := utf16.DecodeRune(r, rune(order.Uint16(src[i+2:]))); pair != unicode.ReplacementChar {
+					dst = appendRune(dst, pair)
+					i += 2
+				} else {
+					dst = append(dst, invalidByte)
+				}
+			}
+		}
+	}
+
+	if final && i < len(src) {
+		// a truncated code unit
+		dst = append(dst, invalidByte)
+		i = len(src)
+	}
+	return dst, i
+}
+
+// transcoder reads UTF-16 or UTF-32 from r as UTF-8.
+type transcoder struct {
+	r   io.Reader
+	enc Encoding
+	// in holds read bytes that do not make a whole character yet, out decoded ones not handed out yet
+	in  []byte
+	out []byte
+	err error
+}
+
+func (t *transcoder) Read(p []byte) (int, error) {
+	for len(t.out) == 0 {
+		if t.err != nil {
+			return 0, t.err
+		}
+		var buf [512]byte
+		n, err := t.r.Read(buf[:])
+		t.in = append(t.in, buf[:n]...)
+		t.err = err
+
+		var used int
+		t.out, used = transcode(t.out[:0], t.in, t.enc, err != nil)
+		t.in = t.in[:copy(t.in, t.in[used:])]
+	}
+
+	n := copy(p, t.out)
+	t.out = t.out[n:]
+	return n, nil
+}
+
+// errorReader fails every read with err.
+type errorReader struct {
+	err error
+}
+
+func (r errorReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
@@ -1,6 +1,9 @@
 package gojson
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+)
 
 type ErrorType int
 
@@ -10,8 +13,18 @@ const (
 	InvalidDataError
 	UndefinedKeywordError
 	IllegalValueLoadingError
+	InvalidEscapeError
+	UnexpectedCharacterError
+	TokenTooLongError
+	UnterminatedStringError
+	UnterminatedCommentError
+	InvalidEncodingError
 
 	SyntaxError
+	UnexpectedEndOfInputError
+	DepthLimitError
+	LimitError
+	DuplicateKeyError
 )
 
 func (et ErrorType) String() string {
@@ -22,44 +35,159 @@ func (et ErrorType) String() string {
 		return "UndefinedKeywordError"
 	case IllegalValueLoadingError:
 		return "IllegalValueLoadingError"
+	case InvalidEscapeError:
+		return "InvalidEscapeError"
+	case UnexpectedCharacterError:
+		return "UnexpectedCharacterError"
+	case TokenTooLongError:
+		return "TokenTooLongError"
+	case UnterminatedStringError:
+		return "UnterminatedStringError"
+	case UnterminatedCommentError:
+		return "UnterminatedCommentError"
+	case InvalidEncodingError:
+		return "InvalidEncodingError"
 	case SyntaxError:
 		return "SyntaxError"
+	case UnexpectedEndOfInputError:
+		return "UnexpectedEndOfInputError"
+	case DepthLimitError:
+		return "DepthLimitError"
+	case LimitError:
+		return "LimitError"
+	case DuplicateKeyError:
+		return "DuplicateKeyError"
 	default:
 		return "UnknownError"
 	}
 }
 
+// Sentinel errors to tell failures apart with errors.Is, each ErrorType matches one of them.
+var (
+	ErrInvalidNumber       = errors.New("invalid number")
+	ErrUndefinedKeyword    = errors.New("undefined keyword")
+	ErrIllegalValueLoading = errors.New("illegal value loading")
+	ErrInvalidEscape       = errors.New("invalid escape sequence")
+	ErrUnexpectedCharacter = errors.New("unexpected character")
+	ErrTokenTooLong        = errors.New("token too long")
+	ErrInvalidEncoding     = errors.New("invalid encoding")
+	ErrSyntax              = errors.New("syntax error")
+	// ErrUnexpectedEOF is matched by an UnexpectedEndOfInputError, and by an
+	// unterminated string or comment, since the input ends inside those as well
+	ErrUnexpectedEOF = errors.New("unexpected end of input")
+	ErrDepthExceeded = errors.New("nesting depth exceeded")
+	ErrLimitExceeded = errors.New("limit exceeded")
+	ErrDuplicateKey  = errors.New("duplicate key")
+	ErrWrongRootType = errors.New("wrong root type")
+)
+
+// Sentinel returns the sentinel error et matches, or nil for UnknownError.
+func (et ErrorType) Sentinel() error {
+	switch et {
+	case InvalidDataError:
+		return ErrInvalidNumber
+	case UndefinedKeywordError:
+		return ErrUndefinedKeyword
+	case IllegalValueLoadingError:
+		return ErrIllegalValueLoading
+	case InvalidEscapeError:
+		return ErrInvalidEscape
+	case UnexpectedCharacterError:
+		return ErrUnexpectedCharacter
+	case TokenTooLongError:
+		return ErrTokenTooLong
+	case UnterminatedStringError, UnterminatedCommentError, UnexpectedEndOfInputError:
+		return ErrUnexpectedEOF
+	case InvalidEncodingError:
+		return ErrInvalidEncoding
+	case SyntaxError:
+		return ErrSyntax
+	case DepthLimitError:
+		return ErrDepthExceeded
+	case LimitError:
+		return ErrLimitExceeded
+	case DuplicateKeyError:
+		return ErrDuplicateKey
+	default:
+		return nil
+	}
+}
+
 type TokenizerError struct {
 	ErrorType    ErrorType
 	ErrorMessage string
-	Letters      []rune
-	StartPos     int
-	EndPos       int
+	Letters      []byte
+	Start        Position
+	End          Position
+	SourceName   string
+	// Err is the error it stems from, if any
+	Err error
 }
 
 func (e *TokenizerError) Error() string {
-	return fmt.Sprintf("[t-%v @ %03d-%03d] %v: `%v`", e.ErrorType.String(), e.StartPos, e.EndPos, e.ErrorMessage, string(e.Letters))
+	if e.SourceName != "" {
+		return fmt.Sprintf("%v:%v: [t-%v] %v: `%v`", e.SourceName, e.Start, e.ErrorType.String(), e.ErrorMessage, string(e.Letters))
+	}
+	return fmt.Sprintf("[t-%v @ %v] %v: `%v`", e.ErrorType.String(), e.Start, e.ErrorMessage, string(e.Letters))
+}
+
+// Is reports whether target is the sentinel error of the ErrorType.
+func (e *TokenizerError) Is(target error) bool {
+	return target != nil && target == e.ErrorType.Sentinel()
+}
+
+func (e *TokenizerError) Unwrap() error {
+	return e.Err
 }
 
 type ParserError struct {
 	ErrorType    ErrorType
 	ErrorMessage string
-	StartPos     int
-	EndPos       int
+	Start        Position
+	End          Position
+	SourceName   string
+	// Path is the path to the offending value such as $.a[0], set for a DepthLimitError, a LimitError
+	// and a DuplicateKeyError
+	Path string
+	// PrevStart and PrevEnd locate the earlier member of a DuplicateKeyError
+	PrevStart Position
+	PrevEnd   Position
 
 	ExpectedType []TokenType
 	FoundType    TokenType
+
+	// Err is the error it stems from, such as the *TokenizerError of a malformed key
+	Err error
 }
 
 func (e *ParserError) Error() string {
-	return fmt.Sprintf("[p-%v @ %03d-%03d] %v", e.ErrorType.String(), e.StartPos, e.EndPos, e.ErrorMessage)
+	if e.SourceName != "" {
+		return fmt.Sprintf("%v:%v: [p-%v] %v", e.SourceName, e.Start, e.ErrorType.String(), e.ErrorMessage)
+	}
+	return fmt.Sprintf("[p-%v @ %v] %v", e.ErrorType.String(), e.Start, e.ErrorMessage)
 }
 
+// Is reports whether target is the sentinel error of the ErrorType.
+func (e *ParserError) Is(target error) bool {
+	return target != nil && target == e.ErrorType.Sentinel()
+}
+
+func (e *ParserError) Unwrap() error {
+	return e.Err
+}
+
+// JsonError reports a root of the wrong kind, such as an array for Json.Map.
 type JsonError struct {
+	Expected NodeType
+	Actual   NodeType
 }
 
 func (e *JsonError) Error() string {
-	return fmt.Sprintf("json error")
+	return fmt.Sprintf("expected the root to be %v, but it is %v", e.Expected, e.Actual)
+}
+
+func (e *JsonError) Is(target error) bool {
+	return target == ErrWrongRootType
 }
 
 //func NewSyntaxError(expect []TokenType, actual Token) *ParserError {
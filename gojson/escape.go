@@ -0,0 +1,175 @@
+package gojson
+
+import (
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// decodeEscape decodes the escape sequence that starts with the backslash at src[pos].
+// It returns the decoded rune and the number of bytes the sequence spans.
+// On failure msg describes the problem and size spans the bytes read so far.
+// A lone surrogate is returned as-is when policy is LoneSurrogateKeep.
+// DialectJSON5 adds the escapes of decodeEscape5.
+func decodeEscape(src []byte, pos int, policy LoneSurrogatePolicy, dialect Dialect) (r rune, size int, msg string) {
+	if pos+1 >= len(src) {
+		return 0, len(src) - pos, "incomplete escape sequence"
+	}
+
+	switch src[pos+1] {
+	case '"', '\\', '/':
+		return rune(src[pos+1]), 2, ""
+	case 'b':
+		return '\b', 2, ""
+	case 'f':
+		return '\f', 2, ""
+	case 'n':
+		return '\n', 2, ""
+	case 'r':
+		return '\r', 2, ""
+	case 't':
+		return '\t', 2, ""
+	case 'u':
+		// handled below
+	default:
+		if dialect == DialectJSON5 {
+			return decodeEscape5(src, pos)
+		}
+		_, width := utf8.DecodeRune(src[pos+1:])
+		return 0, 1 + width, "invalid escape sequence"
+	}
+
+	r, size, msg = decodeHex4(src, pos)
+	if msg != "" || !utf16.IsSurrogate(r) {
+		return r, size, msg
+	}
+
+	// a high surrogate must be followed by an escaped low surrogate
+	next := pos + size
+	if r < 0xDC00 && next+1 < len(src) && src[next] == '\\' && src[next+1] == 'u' {
+		low, lowSize, lowMsg := decodeHex4(src, next)
+		if lowMsg == "" && low >= 0xDC00 && low < 0xE000 {
+			return utf16.DecodeRune(r, low), size + lowSize, ""
+		}
+		// not a pair, leave the second escape for the next round
+	}
+
+	switch policy {
+	case LoneSurrogateReplace:
+		return utf8.RuneError, size, ""
+	case LoneSurrogateKeep:
+		return r, size, ""
+	default:
+		return 0, size, "lone surrogate in escape sequence"
+	}
+}
+
+// decodeEscape5 decodes the escape sequences JSON5 has on top of JSON's:
+// \', \v, \0, \xHH, line continuations and any other character escaping itself.
+// A line continuation decodes to -1, as it stands for no character at all.
+func decodeEscape5(src []byte, pos int) (rune, int, string) {
+	c, width := utf8.DecodeRune(src[pos+1:])
+	switch c {
+	case 'v':
+		return '\v', 2, ""
+	case '0':
+		if pos+2 < len(src) && isDigit(src[pos+2]) {
+			return 0, 3, "octal escape sequences are not allowed"
+		}
+		return 0, 2, ""
+	case '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		return 0, 2, "octal escape sequences are not allowed"
+	case 'x':
+		r, n := decodeHex(src[pos+2:], 2)
+		switch {
+		case n == 2:
+			return r, 4, ""
+		case pos+2+n >= len(src):
+			return 0, len(src) - pos, "incomplete hex escape sequence"
+		default:
+			return 0, 2 + n, "invalid hex escape sequence"
+		}
+	case '\r':
+		if pos+2 < len(src) && src[pos+2] == '\n' {
+			return -1, 3, ""
+		}
+		return -1, 2, ""
+	case '\n', '\u2028', '\u2029':
+		return -1, 1 + width, ""
+	}
+	return c, 1 + width, ""
+}
+
+// decodeHex4 decodes the \uXXXX escape that starts with the backslash at src[pos].
+func decodeHex4(src []byte, pos int) (rune, int, string) {
+	r, n := decodeHex(src[pos+2:], 4)
+	switch {
+	case n == 4:
+		return r, 6, ""
+	case pos+2+n >= len(src):
+		return 0, len(src) - pos, "incomplete unicode escape sequence"
+	default:
+		return 0, 2 + n, "invalid unicode escape sequence"
+	}
+}
+
+// decodeHex decodes up to n hex digits at the start of b and returns how many it read.
+func decodeHex(b []byte, n int) (rune, int) {
+	var r rune
+	for i := 0; i < n && i < len(b); i++ {
+		c := rune(b[i])
+		switch {
+		case '0' <= c && c <= '9':
+			r = r<<4 | (c - '0')
+		case 'a' <= c && c <= 'f':
+			r = r<<4 | (c - 'a' + 10)
+		case 'A' <= c && c <= 'F':
+			r = r<<4 | (c - 'A' + 10)
+		default:
+			return 0, i
+		}
+	}
+	if len(b) < n {
+		return 0, len(b)
+	}
+	return r, n
+}
+
+// unquote decodes the escape sequences in the body of a string token.
+// start is the byte offset of raw in the source, position works out the error positions from it.
+func unquote(raw []byte, start int, position func(int) Position, policy LoneSurrogatePolicy, dialect Dialect) (string, error) {
+	buf := make([]byte, 0, len(raw))
+	for pos := 0; pos < len(raw); {
+		if raw[pos] != '\\' {
+			buf = append(buf, raw[pos])
+			pos++
+			continue
+		}
+
+		r, size, msg := decodeEscape(raw, pos, policy, dialect)
+		if msg != "" {
+			return "", &TokenizerError{
+				ErrorType:    InvalidEscapeError,
+				ErrorMessage: msg,
+				Letters:      raw[pos : pos+size],
+				Start:        position(start + pos),
+				End:          position(start + pos + size),
+			}
+		}
+		if r >= 0 {
+			buf = appendRune(buf, r)
+		}
+		pos += size
+	}
+	return string(buf), nil
+}
+
+// appendRune is utf8.EncodeRune, except that surrogate code points kept by
+// LoneSurrogateKeep are written out as-is instead of as U+FFFD.
+func appendRune(buf []byte, r rune) []byte {
+	if utf16.IsSurrogate(r) {
+		return append(buf, byte(0xE0|r>>12), byte(0x80|(r>>6)&0x3F), byte(0x80|r&0x3F))
+	}
+	var tmp [utf8.UTFMax]byte
+	n := utf8.EncodeRune(tmp[:], r)
+	return append(buf, tmp[:n]...)
+}
@@ -0,0 +1,241 @@
+package gojson
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Sentinel errors a Handler returns to steer ParseEvents, also when wrapped.
+var (
+	// ErrSkipSubtree from StartObject or StartArray skips the object or array, and from Key
+	// the value of the member. What is skipped reports no events, not even its EndObject
+	// or EndArray, but it is checked all the same. Returned from anywhere else, it is ignored.
+	ErrSkipSubtree = errors.New("skip subtree")
+	// ErrStopParsing ends the parsing early, ParseEvents then returns nil.
+	ErrStopParsing = errors.New("stop parsing")
+)
+
+// Handler receives the parts of a document from ParseEvents in the order they are read.
+// An error it returns other than ErrSkipSubtree and ErrStopParsing ends the parsing and is
+// returned from ParseEvents.
+type Handler interface {
+	StartObject() error
+	Key(key string) error
+	EndObject() error
+	StartArray() error
+	EndArray() error
+	String(s string) error
+	// Number gets the token, LoadAsFloat64 decodes it and Raw returns it as written
+	Number(token Token) error
+	Bool(b bool) error
+	Null() error
+}
+
+// NopHandler ignores every event. Embed it to handle only some of them.
+type NopHandler struct{}
+
+func (NopHandler) StartObject() error  { return nil }
+func (NopHandler) Key(string) error    { return nil }
+func (NopHandler) EndObject() error    { return nil }
+func (NopHandler) StartArray() error   { return nil }
+func (NopHandler) EndArray() error     { return nil }
+func (NopHandler) String(string) error { return nil }
+func (NopHandler) Number(Token) error  { return nil }
+func (NopHandler) Bool(bool) error     { return nil }
+func (NopHandler) Null() error         { return nil }
+
+// ParseEvents parses the document of src, such as a Tokenizer or StreamTokenizer,
+// and reports its parts to handler as it goes, without building a tree.
+func ParseEvents(src TokenSource, handler Handler) error {
+	return NewStreamParser(src).ParseEvents(handler)
+}
+
+// ParseEvents is like Parse, but reports the parts of the document to handler instead of
+// building a tree. The options apply as they do to Parse, except that DuplicateKeysLastWins
+// keeps all members, as the value of a later one can not be reported in place of an earlier one,
+// and KeepComments is ignored, as there are no nodes to attach the comments to.
+func (p *Parser) ParseEvents(handler Handler) error {
+	keep := p.Options.KeepComments
+	p.Options.KeepComments = false
+	defer func() { p.Options.KeepComments = keep }()
+
+	err := p.eventValue(handler)
+	if errors.Is(err, ErrStopParsing) {
+		return nil
+	}
+	if _, ok := err.(*ParserError); ok {
+		return p.sourceError(err)
+	}
+	if err != nil {
+		return err
+	}
+	return p.expectEnd()
+}
+
+func (p *Parser) eventValue(h Handler) error {
+	token := p.Token()
+	switch token.Type {
+	case TLCurlyBracket:
+		return p.eventObject(h)
+	case TLSquareBracket:
+		return p.eventArray(h)
+	case TString, TNumber, TTrue, TFalse, TNull:
+		if err := p.addNode(token); err != nil {
+			return err
+		}
+		p.GoNext()
+	default:
+		// reports the unexpected token
+		_, err := p.ParseValue()
+		return err
+	}
+
+	var err error
+	switch token.Type {
+	case TString:
+		s, loadErr := p.loadString(token)
+		if loadErr != nil {
+			return loadErr
+		}
+		err = h.String(s)
+	case TNumber:
+		err = h.Number(token)
+	case TTrue, TFalse:
+		err = h.Bool(token.Type == TTrue)
+	default:
+		err = h.Null()
+	}
+	return ignoreSkip(err)
+}
+
+func ignoreSkip(err error) error {
+	if errors.Is(err, ErrSkipSubtree) {
+		return nil
+	}
+	return err
+}
+
+func (p *Parser) eventObject(h Handler) error {
+	open := p.Token()
+	if err := p.enter(open); err != nil {
+		return err
+	}
+	defer func() { p.Depth-- }()
+	if err := p.addNode(open); err != nil {
+		return err
+	}
+	// consume '{'
+	p.GoNext()
+
+	skip := false
+	if err := h.StartObject(); errors.Is(err, ErrSkipSubtree) {
+		skip, h = true, NopHandler{}
+	} else if err != nil {
+		return err
+	}
+
+	// seen locates the members by key, where a repeated key is not reported
+	var seen map[string]keySeen
+	trackKeys := p.Options.DuplicateKeys == DuplicateKeysError || p.Options.DuplicateKeys == DuplicateKeysFirstWins
+	for n := 0; p.Token().Type != TRCurlyBracket; n++ {
+		if max := p.Options.MaxObjectMembers; max > 0 && n >= max {
+			return p.limitError(p.Token(), fmt.Sprintf("object exceeds the limit of %v members", max))
+		}
+		first := p.Token()
+		key, err := p.parseKey()
+		if err != nil {
+			return err
+		}
+		prev, dup := seen[key]
+
+		// the value of a repeated key is checked only
+		vh := Handler(NopHandler{})
+		if !dup {
+			if err := h.Key(key); err == nil {
+				vh = h
+			} else if !errors.Is(err, ErrSkipSubtree) {
+				return err
+			}
+		}
+		p.path = append(p.path, pathSegment{key: key, index: -1})
+		err = p.eventValue(vh)
+		p.path = p.path[:len(p.path)-1]
+		if err != nil {
+			return err
+		}
+		last := p.PrevToken()
+
+		more, err := p.parseSeparator(TRCurlyBracket, nil)
+		if err != nil {
+			return err
+		}
+
+		if dup && p.Options.DuplicateKeys == DuplicateKeysError {
+			return p.duplicateKeyError(key, first, last, prev)
+		}
+		if !dup && trackKeys {
+			if seen == nil {
+				seen = map[string]keySeen{}
+			}
+			seen[key] = keySeen{index: n, first: first, last: last}
+		}
+
+		if !more {
+			break
+		}
+	}
+	// consume '}'
+	p.GoNext()
+
+	if skip {
+		return nil
+	}
+	return ignoreSkip(h.EndObject())
+}
+
+func (p *Parser) eventArray(h Handler) error {
+	open := p.Token()
+	if err := p.enter(open); err != nil {
+		return err
+	}
+	defer func() { p.Depth-- }()
+	if err := p.addNode(open); err != nil {
+		return err
+	}
+	// consume '['
+	p.GoNext()
+
+	skip := false
+	if err := h.StartArray(); errors.Is(err, ErrSkipSubtree) {
+		skip, h = true, NopHandler{}
+	} else if err != nil {
+		return err
+	}
+
+	for n := 0; p.Token().Type != TRSquareBracket; n++ {
+		if max := p.Options.MaxArrayElements; max > 0 && n >= max {
+			return p.limitError(p.Token(), fmt.Sprintf("array exceeds the limit of %v elements", max))
+		}
+		p.path = append(p.path, pathSegment{index: n})
+		err := p.eventValue(h)
+		p.path = p.path[:len(p.path)-1]
+		if err != nil {
+			return err
+		}
+
+		more, err := p.parseSeparator(TRSquareBracket, nil)
+		if err != nil {
+			return err
+		}
+		if !more {
+			break
+		}
+	}
+	// consume ']'
+	p.GoNext()
+
+	if skip {
+		return nil
+	}
+	return ignoreSkip(h.EndArray())
+}
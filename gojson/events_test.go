@@ -0,0 +1,176 @@
+package gojson
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// eventRecorder writes down the events, and returns the error of the event named in stop.
+type eventRecorder struct {
+	events []string
+	stop   map[string]error
+}
+
+func (r *eventRecorder) record(event string) error {
+	r.events = append(r.events, event)
+	return r.stop[event]
+}
+
+func (r *eventRecorder) StartObject() error { return r.record("{") }
+func (r *eventRecorder) Key(key string) error {
+	return r.record("key " + key)
+}
+func (r *eventRecorder) EndObject() error  { return r.record("}") }
+func (r *eventRecorder) StartArray() error { return r.record("[") }
+func (r *eventRecorder) EndArray() error   { return r.record("]") }
+func (r *eventRecorder) String(s string) error {
+	return r.record(fmt.Sprintf("%q", s))
+}
+func (r *eventRecorder) Number(token Token) error {
+	f, err := token.LoadAsFloat64()
+	if err != nil {
+		return err
+	}
+	return r.record(fmt.Sprint(f))
+}
+func (r *eventRecorder) Bool(b bool) error { return r.record(fmt.Sprint(b)) }
+func (r *eventRecorder) Null() error       { return r.record("null") }
+
+func TestParseEvents(t *testing.T) {
+	json := `{"msg": "hi\n", "list": [1, 2.5, true, null, {}], "sub": {"ok": false}}`
+	want := []string{"{", "key msg", `"hi\n"`, "key list", "[", "1", "2.5", "true", "null", "{", "}", "]",
+		"key sub", "{", "key ok", "false", "}", "}"}
+
+	r := &eventRecorder{}
+	assert.NoError(t, ParseEvents(NewTokenizer(json), r))
+	assert.Equal(t, want, r.events)
+
+	r = &eventRecorder{}
+	assert.NoError(t, ParseEvents(NewStreamTokenizer(iotest.OneByteReader(strings.NewReader(json))), r))
+	assert.Equal(t, want, r.events)
+
+	r = &eventRecorder{}
+	assert.NoError(t, ParseEvents(NewTokenizer(`"root"`), r))
+	assert.Equal(t, []string{`"root"`}, r.events)
+}
+
+func TestParseEvents_Skip(t *testing.T) {
+	json := `{"a": [1, [2]], "b": {"c": 3}, "d": 4}`
+	tests := []struct {
+		name string
+		stop map[string]error
+		want []string
+	}{
+		{
+			"array",
+			map[string]error{"[": ErrSkipSubtree},
+			[]string{"{", "key a", "[", "key b", "{", "key c", "3", "}", "key d", "4", "}"},
+		},
+		{
+			"member",
+			map[string]error{"key b": ErrSkipSubtree},
+			[]string{"{", "key a", "[", "1", "[", "2", "]", "]", "key b", "key d", "4", "}"},
+		},
+		{
+			"root",
+			map[string]error{"{": ErrSkipSubtree},
+			[]string{"{"},
+		},
+		{
+			"ignored",
+			map[string]error{"1": ErrSkipSubtree, "}": ErrSkipSubtree},
+			[]string{"{", "key a", "[", "1", "[", "2", "]", "]", "key b", "{", "key c", "3", "}", "key d", "4", "}"},
+		},
+		{
+			"wrapped",
+			map[string]error{"key a": fmt.Errorf("not wanted: %w", ErrSkipSubtree), "key d": fmt.Errorf("done: %w", ErrStopParsing)},
+			[]string{"{", "key a", "key b", "{", "key c", "3", "}", "key d"},
+		},
+		{
+			"stop",
+			map[string]error{"key c": ErrStopParsing},
+			[]string{"{", "key a", "[", "1", "[", "2", "]", "]", "key b", "{", "key c"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &eventRecorder{stop: tt.stop}
+			assert.NoError(t, ParseEvents(NewTokenizer(json), r))
+			assert.Equal(t, tt.want, r.events)
+		})
+	}
+
+	// what is skipped is still checked
+	r := &eventRecorder{stop: map[string]error{"key a": ErrSkipSubtree}}
+	err := ParseEvents(NewTokenizer(`{"a": [1 2], "b": 3}`), r)
+	var psErr *ParserError
+	if assert.ErrorAs(t, err, &psErr) {
+		assert.Equal(t, SyntaxError, psErr.ErrorType)
+		assert.Equal(t, 9, psErr.Start.Offset)
+	}
+	assert.Equal(t, []string{"{", "key a"}, r.events)
+
+	// the handler's own errors are returned as they are
+	failed := errors.New("failed")
+	r = &eventRecorder{stop: map[string]error{"2": failed}}
+	assert.Equal(t, failed, ParseEvents(NewTokenizer(`[1, 2, 3]`), r))
+}
+
+func TestParser_ParseEvents_Errors(t *testing.T) {
+	tests := []struct {
+		name      string
+		json      string
+		options   ParserOptions
+		errorType ErrorType
+		want      []string
+	}{
+		{"trailing", `[1] 2`, ParserOptions{}, SyntaxError, []string{"[", "1", "]"}},
+		{"unclosed", `{"a": [1`, ParserOptions{}, UnexpectedEndOfInputError, []string{"{", "key a", "[", "1"}},
+		{"key", `{1: 2}`, ParserOptions{}, SyntaxError, []string{"{"}},
+		{"depth", `[[[1]]]`, ParserOptions{MaxDepth: 2}, DepthLimitError, []string{"[", "["}},
+		{"elements", `[1, 2, 3]`, ParserOptions{MaxArrayElements: 2}, LimitError, []string{"[", "1", "2"}},
+		{"nodes", `{"a": 1, "b": 2}`, ParserOptions{MaxNodes: 3}, LimitError, []string{"{", "key a", "1"}},
+		{"duplicate", `{"a": 1, "a": 2}`, ParserOptions{DuplicateKeys: DuplicateKeysError}, DuplicateKeyError, []string{"{", "key a", "1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ps := NewStreamParser(NewTokenizer(tt.json))
+			ps.Options = tt.options
+			r := &eventRecorder{}
+			err := ps.ParseEvents(r)
+			var psErr *ParserError
+			if assert.ErrorAs(t, err, &psErr) {
+				assert.Equal(t, tt.errorType, psErr.ErrorType)
+			}
+			assert.Equal(t, tt.want, r.events)
+		})
+	}
+
+	ps := NewStreamParser(NewTokenizer(`{"a": 1, "b": 2, "a": [3]}`))
+	ps.Options.DuplicateKeys = DuplicateKeysFirstWins
+	r := &eventRecorder{}
+	assert.NoError(t, ps.ParseEvents(r))
+	assert.Equal(t, []string{"{", "key a", "1", "key b", "2", "}"}, r.events)
+
+	tk := NewTokenizer(`[1 /* a */, 2] // b`)
+	tk.Options.Comments = true
+	ps = NewStreamParser(tk)
+	ps.Options.KeepComments = true
+	r = &eventRecorder{}
+	assert.NoError(t, ps.ParseEvents(r))
+	assert.Equal(t, []string{"[", "1", "2", "]"}, r.events)
+	assert.Empty(t, ps.comments)
+	assert.True(t, ps.Options.KeepComments)
+
+	r = &eventRecorder{}
+	err := ParseEvents(NewTokenizer(`["a", "\q"]`), r)
+	var tkErr *TokenizerError
+	if assert.ErrorAs(t, err, &tkErr) {
+		assert.Equal(t, InvalidEscapeError, tkErr.ErrorType)
+	}
+	assert.Equal(t, []string{"[", `"a"`}, r.events)
+}
@@ -0,0 +1,180 @@
+package gojson
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Describe returns a readable name of the token type for messages, such as '}' or a string.
+func (tokenType TokenType) Describe() string {
+	switch tokenType {
+	case TEof:
+		return "the end of input"
+	case TNull:
+		return "'null'"
+	case TString:
+		return "a string"
+	case TNumber:
+		return "a number"
+	case TTrue:
+		return "'true'"
+	case TFalse:
+		return "'false'"
+	case TWhiteSpace:
+		return "whitespace"
+	case TComma:
+		return "','"
+	case TColon:
+		return "':'"
+	case TLCurlyBracket:
+		return "'{'"
+	case TRCurlyBracket:
+		return "'}'"
+	case TLSquareBracket:
+		return "'['"
+	case TRSquareBracket:
+		return "']'"
+	case TComment:
+		return "a comment"
+	case TIdentifier:
+		return "an identifier"
+	default:
+		return "an unknown token"
+	}
+}
+
+// describeTypes lists the token types for messages, as in ',', ':' or '}'.
+func describeTypes(types []TokenType) string {
+	var names []string
+	for _, typ := range types {
+		names = append(names, typ.Describe())
+	}
+	if len(names) < 2 {
+		return strings.Join(names, "")
+	}
+	return strings.Join(names[:len(names)-1], ", ") + " or " + names[len(names)-1]
+}
+
+const (
+	ansiReset = "\x1b[0m"
+	ansiBold  = "\x1b[1m"
+	ansiRed   = "\x1b[1;31m"
+	ansiBlue  = "\x1b[1;34m"
+)
+
+// FormatError renders err for people: the message, the line of src it is on with the
+// offending range underlined, and the tokens that were expected, if any.
+// src is the input as given to the tokenizer. Errors that do not wrap a *TokenizerError
+// or *ParserError are returned as they print.
+//
+//	data.json:2:4: SyntaxError: expected `,` or `]`, but found `}`
+//	  2 |   3}
+//	    |    ^
+//	    = expected ',' or ']'
+func FormatError(src []byte, err error) string {
+	return formatError(src, err, false)
+}
+
+// FormatErrorColor is like FormatError, but highlights with ANSI escape codes for a terminal.
+func FormatErrorColor(src []byte, err error) string {
+	return formatError(src, err, true)
+}
+
+func formatError(src []byte, err error, color bool) string {
+	var name, msg string
+	var errorType ErrorType
+	var start, end Position
+	var expected []TokenType
+	var tkErr *TokenizerError
+	var psErr *ParserError
+	switch {
+	case errors.As(err, &psErr):
+		name, msg, errorType, start, end = psErr.SourceName, psErr.ErrorMessage, psErr.ErrorType, psErr.Start, psErr.End
+		expected = psErr.ExpectedType
+	case errors.As(err, &tkErr):
+		name, msg, errorType, start, end = tkErr.SourceName, tkErr.ErrorMessage, tkErr.ErrorType, tkErr.Start, tkErr.End
+	default:
+		return err.Error()
+	}
+
+	paint := func(code string, s string) string {
+		if !color {
+			return s
+		}
+		return code + s + ansiReset
+	}
+
+	var buf strings.Builder
+	location := start.String()
+	if name != "" {
+		location = name + ":" + location
+	}
+	fmt.Fprintf(&buf, "%v %v %v\n", paint(ansiBold, location+":"), paint(ansiRed, errorType.String()+":"), paint(ansiBold, msg))
+
+	line, ok := sourceLine(src, start.Line)
+	if !ok {
+		return strings.TrimSuffix(buf.String(), "\n")
+	}
+	number := fmt.Sprint(start.Line)
+	gutter := strings.Repeat(" ", len(number))
+	fmt.Fprintf(&buf, " %v %v\n", paint(ansiBlue, number+" |"), line)
+
+	// the underline follows the tabs of the line, so that it lines up
+	var pad strings.Builder
+	runes := 0
+	for _, r := range line {
+		if runes >= start.Column-1 {
+			break
+		}
+		if r == '\t' {
+			pad.WriteByte('\t')
+		} else {
+			pad.WriteByte(' ')
+		}
+		runes++
+	}
+	for ; runes < start.Column-1; runes++ {
+		pad.WriteByte(' ')
+	}
+	width := 1
+	if end.Line == start.Line && end.Column > start.Column {
+		width = end.Column - start.Column
+	} else if end.Line > start.Line {
+		// up to the end of the line
+		if n := utf8.RuneCountInString(line) - (start.Column - 1); n > width {
+			width = n
+		}
+	}
+	fmt.Fprintf(&buf, " %v %v%v", paint(ansiBlue, gutter+" |"), pad.String(), paint(ansiRed, "^"+strings.Repeat("~", width-1)))
+
+	if len(expected) > 0 {
+		fmt.Fprintf(&buf, "\n %v expected %v", paint(ansiBlue, gutter+" ="), describeTypes(expected))
+	}
+	return buf.String()
+}
+
+// sourceLine returns the line of src with the number n, counted from 1 as in Position.
+func sourceLine(src []byte, n int) (string, bool) {
+	enc, bomSize := DetectEncoding(src)
+	src = src[bomSize:]
+	if enc != EncodingUTF8 {
+		src, _ = transcode(nil, src, enc, true)
+	}
+	if n < 1 {
+		return "", false
+	}
+	for i := 1; i < n; i++ {
+		nl := bytes.IndexByte(src, '\n')
+		if nl < 0 {
+			return "", false
+		}
+		src = src[nl+1:]
+	}
+	if nl := bytes.IndexByte(src, '\n'); nl >= 0 {
+		src = src[:nl]
+	}
+	return string(bytes.TrimSuffix(src, []byte{'\r'})), true
+}
@@ -0,0 +1,49 @@
+package gojson_test
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/x0y14/gojson/gojson"
+	"testing"
+)
+
+func TestFormatError(t *testing.T) {
+	src := "[1, 2,\n  3}"
+	ps := Setup(src)
+	ps.SourceName = "data.json"
+	_, err := ps.Parse()
+	assert.Equal(t, "data.json:2:4: SyntaxError: expected `,` or `]`, but found `}`\n"+
+		" 2 |   3}\n"+
+		"   |    ^\n"+
+		"   = expected ',' or ']'", gojson.FormatError([]byte(src), err))
+
+	// the whole token is underlined, in line with tabs
+	src = "{\n\t\"a\": tru}"
+	_, keywordErr := gojson.NewTokenizer(src).Tokenize()
+	assert.Equal(t, "2:7: UndefinedKeywordError: undefined keyword\n"+
+		" 2 | \t\"a\": tru}\n"+
+		"   | \t     ^~~", gojson.FormatError([]byte(src), keywordErr))
+
+	// at the end of input
+	src = "{\"a\": 1"
+	_, err = Setup(src).Parse()
+	assert.Equal(t, "1:8: UnexpectedEndOfInputError: expected `,` or `}`, but found ``\n"+
+		" 1 | {\"a\": 1\n"+
+		"   |        ^\n"+
+		"   = expected ',' or '}'", gojson.FormatError([]byte(src), err))
+
+	// colour
+	assert.Equal(t, "\x1b[1m2:7:\x1b[0m \x1b[1;31mUndefinedKeywordError:\x1b[0m \x1b[1mundefined keyword\x1b[0m\n"+
+		" \x1b[1;34m2 |\x1b[0m \t\"a\": tru}\n"+
+		" \x1b[1;34m  |\x1b[0m \t     \x1b[1;31m^~~\x1b[0m",
+		gojson.FormatErrorColor([]byte("{\n\t\"a\": tru}"), keywordErr))
+
+	// other errors print as they are
+	assert.Equal(t, "boom", gojson.FormatError(nil, errors.New("boom")))
+}
+
+func TestTokenType_Describe(t *testing.T) {
+	assert.Equal(t, "'}'", gojson.TRCurlyBracket.Describe())
+	assert.Equal(t, "a string", gojson.TString.Describe())
+	assert.Equal(t, "the end of input", gojson.TEof.Describe())
+}
@@ -5,15 +5,17 @@ import (
 	"strings"
 )
 
-// Json     := Object
-//		     | Array
+// Json     := Value TEof
 
 // Object   := "{" Member? "}"
+//		     | "{" Member "," "}"      (AllowTrailingComma)
 // Array    := "[" Elements? "]"
+//		     | "[" Elements "," "]"    (AllowTrailingComma)
 
 // Member   := Pair
 //		     | Pair "," Member
 // Pair     := TString ":" Value
+//		     | TIdentifier ":" Value   (JSON5)
 
 // Elements := Value
 //           | Value "," Elements
@@ -33,13 +35,25 @@ func NewJson(node *Node, rootNodeType NodeType) *Json {
 }
 
 type Json struct {
-	node         *Node
+	node *Node
+	// RootNodeType is NDObject, NDArray or, for a scalar document, NDValue
 	RootNodeType NodeType
 }
 
+// Node returns the root node of the document.
+func (j *Json) Node() *Node {
+	return j.node
+}
+
+// Value maps the document whatever its root is: a map[string]interface{}
+// for an object, a []interface{} for an array, or the scalar value itself.
+func (j *Json) Value() (interface{}, error) {
+	return j.ElementMapping(j.node)
+}
+
 func (j *Json) Map() (map[string]interface{}, error) {
 	if j.RootNodeType != NDObject {
-		panic(JsonError{})
+		return nil, &JsonError{Expected: NDObject, Actual: j.RootNodeType}
 	}
 
 	obj, err := j.ObjectMapping(j.node)
@@ -52,7 +66,7 @@ func (j *Json) Map() (map[string]interface{}, error) {
 
 func (j *Json) Array() ([]interface{}, error) {
 	if j.RootNodeType != NDArray {
-		panic(JsonError{})
+		return nil, &JsonError{Expected: NDArray, Actual: j.RootNodeType}
 	}
 
 	arr, err := j.ArrayMapping(j.node)
@@ -63,6 +77,58 @@ func (j *Json) Array() ([]interface{}, error) {
 	return arr, nil
 }
 
+// OrderedMap is like Map, but keeps the order of the members of every object,
+// including those nested in arrays.
+func (j *Json) OrderedMap() (*OrderedMap, error) {
+	if j.RootNodeType != NDObject {
+		return nil, &JsonError{Expected: NDObject, Actual: j.RootNodeType}
+	}
+
+	return j.OrderedObjectMapping(j.node)
+}
+
+// OrderedObjectMapping maps obj like ObjectMapping, keeping its members in order.
+// The value of a repeated key takes the place of its first member.
+func (j *Json) OrderedObjectMapping(obj *Node) (*OrderedMap, error) {
+	result := NewOrderedMap()
+
+	for i := range *obj.Children {
+		pair := &(*obj.Children)[i]
+		value, err := j.OrderedElementMapping(&(*pair.Children)[0])
+		if err != nil {
+			return nil, err
+		}
+
+		result.Set(pair.Key, value)
+	}
+	return result, nil
+}
+
+// OrderedArrayMapping maps arr like ArrayMapping, with the objects in it as *OrderedMap.
+func (j *Json) OrderedArrayMapping(arr *Node) ([]interface{}, error) {
+	var result []interface{}
+	for i := range *arr.Children {
+		value, err := j.OrderedElementMapping(&(*arr.Children)[i])
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, value)
+	}
+	return result, nil
+}
+
+// OrderedElementMapping maps element like ElementMapping, with objects as *OrderedMap.
+func (j *Json) OrderedElementMapping(element *Node) (interface{}, error) {
+	switch element.Type {
+	case NDObject:
+		return j.OrderedObjectMapping(element)
+	case NDArray:
+		return j.OrderedArrayMapping(element)
+	default:
+		return j.ValueMapping(element)
+	}
+}
+
 func (j *Json) ObjectMapping(obj *Node) (map[string]interface{}, error) {
 	result := map[string]interface{}{}
 
@@ -92,8 +158,14 @@ func (j *Json) PairMapping(pair *Node) (string, interface{}, error) {
 		}
 	case NDArray:
 		value, err = j.ArrayMapping(&child)
+		if err != nil {
+			return "", nil, err
+		}
 	default:
-		value = j.ValueMapping(&child)
+		value, err = j.ValueMapping(&child)
+		if err != nil {
+			return "", nil, err
+		}
 	}
 	return key, value, nil
 }
@@ -125,12 +197,15 @@ func (j *Json) ElementMapping(element *Node) (interface{}, error) {
 		}
 		return value, nil
 	default:
-		value := j.ValueMapping(element)
+		value, err := j.ValueMapping(element)
+		if err != nil {
+			return nil, err
+		}
 		return value, nil
 	}
 }
 
-func (j *Json) ValueMapping(val *Node) interface{} {
+func (j *Json) ValueMapping(val *Node) (interface{}, error) {
 	switch val.Val.Type {
 	case TTrue, TFalse:
 		return val.Val.LoadAsBoolean()
@@ -151,6 +226,8 @@ func (j *Json) Tree() {
 		j.ObjectTree(1, j.node)
 	case NDArray:
 		j.ArrayTree(1, j.node)
+	default:
+		j.ShowValue(1, j.node)
 	}
 }
 
@@ -199,13 +276,13 @@ func (j *Json) ElementTree(index int, nest int, element *Node) {
 func (j *Json) ShowValue(nest int, val *Node) {
 	switch val.Val.Type {
 	case TTrue, TFalse:
-		fmt.Printf("%v`%v`(%v)\n", Indent(nest), val.Val.LoadAsBoolean(), val.Val.Type.String())
+		fmt.Printf("%v`%v`(%v)\n", Indent(nest), val.Val.MustLoadAsBoolean(), val.Val.Type.String())
 	case TNull:
-		fmt.Printf("%v`%v`(%v)\n", Indent(nest), val.Val.LoadAsNull(), val.Val.Type.String())
+		fmt.Printf("%v`%v`(%v)\n", Indent(nest), val.Val.MustLoadAsNull(), val.Val.Type.String())
 	case TNumber:
-		fmt.Printf("%v`%v`(%v)\n", Indent(nest), val.Val.LoadAsFloat64(), val.Val.Type.String())
+		fmt.Printf("%v`%v`(%v)\n", Indent(nest), val.Val.MustLoadAsFloat64(), val.Val.Type.String())
 	case TString:
-		fmt.Printf("%v`%v`(%v)\n", Indent(nest), val.Val.LoadAsString(), val.Val.Type.String())
+		fmt.Printf("%v`%v`(%v)\n", Indent(nest), val.Val.MustLoadAsString(), val.Val.Type.String())
 	}
 }
 
@@ -1,6 +1,7 @@
 package gojson
 
 import (
+	"errors"
 	"github.com/stretchr/testify/assert"
 	"testing"
 )
@@ -34,7 +35,7 @@ func TestJson_Tree(t *testing.T) {
 
 	for _, tt := range tests {
 		tk := NewTokenizer(tt.json)
-		ps := NewParser(tk.Tokenize())
+		ps := NewParser(tk.MustTokenize())
 		nd, err := ps.Parse()
 		if err != nil {
 			t.Fatal(err)
@@ -72,7 +73,7 @@ func TestJson_Map(t *testing.T) {
 
 	for _, tt := range tests {
 		tk := NewTokenizer(tt.json)
-		ps := NewParser(tk.Tokenize())
+		ps := NewParser(tk.MustTokenize())
 		nd, err := ps.Parse()
 		if err != nil {
 			t.Fatal(err)
@@ -97,7 +98,7 @@ func TestJson_Map2(t *testing.T) {
 		"{\"name\":\"tom\", \"age\": 12, \"active\": false, \"skill\": null}]" +
 		"}"
 	tk := NewTokenizer(json)
-	ps := NewParser(tk.Tokenize())
+	ps := NewParser(tk.MustTokenize())
 	nd, err := ps.Parse()
 	if err != nil {
 		t.Fatal(err)
@@ -114,3 +115,132 @@ func TestJson_Map2(t *testing.T) {
 	//fmt.Printf("%v", members[0])
 	assert.Equal(t, "sadako", members[1])
 }
+
+func TestJson_Map_JSON5(t *testing.T) {
+	// the example from https://spec.json5.org
+	json := `// This file is written in JSON5 syntax, naturally, but npm needs a regular
+// JSON file, so compile via ` + "`npm run build`" + `. Be sure to keep both in sync!
+
+{
+  // comments
+  unquoted: 'and you can quote me on that',
+  singleQuotes: 'I can use "double quotes" here',
+  lineBreaks: "Look, Mom! \
+No \\n's!",
+  hexadecimal: 0xdecaf,
+  leadingDecimalPoint: .8675309, andTrailing: 8675309.,
+  positiveSign: +1,
+  trailingComma: 'in objects', andIn: ['arrays',],
+  "backwardsCompatible": "with JSON",
+}
+`
+	tk := NewTokenizer(json)
+	tk.Options.Dialect = DialectJSON5
+	ps := NewParser(tk.MustTokenize())
+	ps.Options.Dialect = DialectJSON5
+	nd, err := ps.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mp, err := nd.Map()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, map[string]interface{}{
+		"unquoted":            "and you can quote me on that",
+		"singleQuotes":        `I can use "double quotes" here`,
+		"lineBreaks":          `Look, Mom! No \n's!`,
+		"hexadecimal":         float64(0xdecaf),
+		"leadingDecimalPoint": .8675309,
+		"andTrailing":         float64(8675309),
+		"positiveSign":        float64(1),
+		"trailingComma":       "in objects",
+		"andIn":               []interface{}{"arrays"},
+		"backwardsCompatible": "with JSON",
+	}, mp)
+
+	// strict JSON stays the default
+	_, err = NewTokenizer(json).Tokenize()
+	assert.Error(t, err)
+
+	// keys may be spelled like keywords and numbers
+	tk = NewTokenizer("{true: 1, false: 2, null: 3, Infinity: 4, NaN: 5, \\u0074rue: 6, if: 7}")
+	tk.Options.Dialect = DialectJSON5
+	ps = NewParser(tk.MustTokenize())
+	ps.Options.Dialect = DialectJSON5
+	js, err := ps.Parse()
+	if assert.NoError(t, err) {
+		mp, err = js.Map()
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{
+			"true": float64(6), "false": float64(2), "null": float64(3), "Infinity": float64(4), "NaN": float64(5), "if": float64(7),
+		}, mp)
+	}
+	for _, json := range []string{"{-Infinity: 1}", "{+NaN: 1}", "{1: 1}"} {
+		tk = NewTokenizer(json)
+		tk.Options.Dialect = DialectJSON5
+		ps = NewParser(tk.MustTokenize())
+		ps.Options.Dialect = DialectJSON5
+		_, err = ps.Parse()
+		assert.Error(t, err, json)
+	}
+
+	// identifier keys need the parser to be told about JSON5 as well
+	for _, json := range []string{"{unquoted: 1}", "{true: 1}"} {
+		tk = NewTokenizer(json)
+		tk.Options.Dialect = DialectJSON5
+		_, err = NewParser(tk.MustTokenize()).Parse()
+		assert.Error(t, err, json)
+	}
+}
+
+func TestJson_OrderedMap(t *testing.T) {
+	json := `{"z": 1, "a": {"y": true, "b": null}, "m": [{"q": "x", "c": [{"k": 2, "j": 3}]}, 4]}`
+	js, err := NewParser(NewTokenizer(json).MustTokenize()).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mp, err := js.OrderedMap()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"z", "a", "m"}, mp.Keys())
+
+	a, _ := mp.Get("a")
+	assert.Equal(t, []string{"y", "b"}, a.(*OrderedMap).Keys())
+
+	m, _ := mp.Get("m")
+	first := m.([]interface{})[0].(*OrderedMap)
+	assert.Equal(t, []string{"q", "c"}, first.Keys())
+	c, _ := first.Get("c")
+	assert.Equal(t, []string{"k", "j"}, c.([]interface{})[0].(*OrderedMap).Keys())
+	assert.Equal(t, float64(4), m.([]interface{})[1])
+
+	// the order survives a round trip
+	b, err := mp.MarshalJSON()
+	assert.NoError(t, err)
+	assert.Equal(t, `{"z":1,"a":{"y":true,"b":null},"m":[{"q":"x","c":[{"k":2,"j":3}]},4]}`, string(b))
+
+	// objects in a root array too
+	js, _ = NewParser(NewTokenizer(`[{"b": 1, "a": 2}]`).MustTokenize()).Parse()
+	arr, err := js.OrderedArrayMapping(js.Node())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"b", "a"}, arr[0].(*OrderedMap).Keys())
+}
+
+func TestJson_WrongRootType(t *testing.T) {
+	js, err := NewParser(NewTokenizer("[1]").MustTokenize()).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = js.Map()
+	assert.True(t, errors.Is(err, ErrWrongRootType))
+	assert.Equal(t, &JsonError{Expected: NDObject, Actual: NDArray}, err)
+	assert.EqualError(t, err, "expected the root to be NDObject, but it is NDArray")
+	_, err = js.OrderedMap()
+	assert.True(t, errors.Is(err, ErrWrongRootType))
+
+	js, _ = NewParser(NewTokenizer("{}").MustTokenize()).Parse()
+	_, err = js.Array()
+	assert.Equal(t, &JsonError{Expected: NDArray, Actual: NDObject}, err)
+}
@@ -0,0 +1,184 @@
+package gojson
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+)
+
+// BadLinePolicy decides what an NDJSONReader does with a line that is not valid JSON.
+type BadLinePolicy int
+
+const (
+	// BadLinesReport returns the error of the line from Read, the next Read goes on behind it.
+	BadLinesReport BadLinePolicy = iota
+	// BadLinesSkip skips the line.
+	BadLinesSkip
+	// BadLinesCollect skips the line and adds its error to NDJSONReader.Errors.
+	BadLinesCollect
+)
+
+// LineError is the error of a line of NDJSON. The positions of Err are those in the
+// whole input, so its message names the line as well.
+type LineError struct {
+	Line int
+	Err  error
+}
+
+func (e *LineError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *LineError) Unwrap() error {
+	return e.Err
+}
+
+// NDJSONReader reads newline-delimited JSON, also known as JSON Lines: one document
+// per line, ended by LF or CRLF. Blank lines are skipped.
+type NDJSONReader struct {
+	// SourceName is the file name or the like reported in errors
+	SourceName       string
+	TokenizerOptions TokenizerOptions
+	Options          ParserOptions
+	BadLines         BadLinePolicy
+	// Errors are the errors of the lines skipped with BadLinesCollect
+	Errors []*LineError
+
+	r *bufio.Reader
+	// line is the number of the line last read and next the position of the one after it
+	line int
+	next Position
+	err  error
+}
+
+func NewNDJSONReader(r io.Reader) *NDJSONReader {
+	return &NDJSONReader{
+		r:    bufio.NewReader(r),
+		next: Position{Line: 1, Column: 1},
+	}
+}
+
+// Line returns the number of the line last read, starting at 1.
+func (r *NDJSONReader) Line() int {
+	return r.line
+}
+
+// Read returns the document of the next line that is not blank, or io.EOF after the last one.
+// The error of a bad line is a *LineError. Exceeding TokenizerOptions.MaxInputSize,
+// which limits the whole input, ends the reading like a failure of the reader does.
+func (r *NDJSONReader) Read() (*Json, error) {
+	for {
+		line, start, err := r.nextLine()
+		if err != nil {
+			return nil, err
+		}
+		js, err := r.parse(line, start)
+		if err == nil {
+			return js, nil
+		}
+		lineErr := &LineError{Line: start.Line, Err: err}
+		if r.exceeds(line, start) {
+			r.err = lineErr
+			return nil, lineErr
+		}
+		switch r.BadLines {
+		case BadLinesSkip:
+			continue
+		case BadLinesCollect:
+			r.Errors = append(r.Errors, lineErr)
+			continue
+		}
+		return nil, lineErr
+	}
+}
+
+// nextLine returns the next line that is not blank, without its line ending, and where it starts.
+func (r *NDJSONReader) nextLine() ([]byte, Position, error) {
+	for r.err == nil {
+		line, err := r.readLine()
+		if err != nil {
+			// the line read up to the failure is still handled
+			r.err = err
+		}
+		if r.next.Offset == 0 {
+			line = bytes.TrimPrefix(line, []byte{0xEF, 0xBB, 0xBF})
+		}
+		start := r.next
+		r.line = start.Line
+		r.next = advance(start, line)
+
+		line = bytes.TrimSuffix(line, []byte{'\n'})
+		line = bytes.TrimSuffix(line, []byte{'\r'})
+		if len(bytes.Trim(line, " \t")) == 0 {
+			continue
+		}
+		return line, start, nil
+	}
+	return nil, Position{}, r.err
+}
+
+// exceeds reports whether line, starting at start, goes beyond TokenizerOptions.MaxInputSize.
+func (r *NDJSONReader) exceeds(line []byte, start Position) bool {
+	max := r.TokenizerOptions.MaxInputSize
+	return max > 0 && start.Offset+len(line) > max
+}
+
+// readLine reads up to and including the next LF. Once the line exceeds MaxInputSize,
+// it stops reading, the tokenizer reports the limit.
+func (r *NDJSONReader) readLine() ([]byte, error) {
+	var line []byte
+	for {
+		chunk, err := r.r.ReadSlice('\n')
+		line = append(line, chunk...)
+		if err != bufio.ErrBufferFull {
+			if err == io.EOF && len(line) > 0 {
+				// the last line lacks its LF
+				return line, nil
+			}
+			return line, err
+		}
+		if max := r.TokenizerOptions.MaxInputSize; max > 0 && r.next.Offset+len(line) > max {
+			return line, nil
+		}
+	}
+}
+
+// parse parses line, which starts at start in the input.
+func (r *NDJSONReader) parse(line []byte, start Position) (*Json, error) {
+	tk := &Tokenizer{
+		Source:     line,
+		SourceName: r.SourceName,
+		Options:    r.TokenizerOptions,
+		base:       start.Offset,
+		src:        newSource(line, start, r.TokenizerOptions),
+	}
+	tokens, err := tk.Tokenize()
+	if err != nil {
+		return nil, err
+	}
+	ps := NewParser(tokens)
+	ps.SourceName = r.SourceName
+	ps.Options = r.Options
+	return ps.Parse()
+}
+
+// NDJSONWriter writes newline-delimited JSON, one compact document per line.
+type NDJSONWriter struct {
+	w   io.Writer
+	buf []byte
+}
+
+func NewNDJSONWriter(w io.Writer) *NDJSONWriter {
+	return &NDJSONWriter{w: w}
+}
+
+// Write writes js as one line.
+func (w *NDJSONWriter) Write(js *Json) error {
+	buf, err := js.AppendCompact(w.buf[:0])
+	if err != nil {
+		return err
+	}
+	w.buf = append(buf, '\n')
+	_, err = w.w.Write(w.buf)
+	return err
+}
@@ -0,0 +1,186 @@
+package gojson
+
+import (
+	"context"
+	"io"
+	"runtime"
+	"sync"
+)
+
+// NDJSONParallelOptions configures ParseNDJSONParallel.
+type NDJSONParallelOptions struct {
+	// SourceName is the file name or the like reported in errors
+	SourceName       string
+	TokenizerOptions TokenizerOptions
+	Options          ParserOptions
+
+	// Workers is the number of goroutines parsing lines, 0 means runtime.GOMAXPROCS(0)
+	Workers int
+	// Ordered delivers the results in the order of the lines, rather than as they are done
+	Ordered bool
+	// Buffer is how many lines may be read ahead of the results taken from the channel,
+	// 0 means 16 per worker. The reading waits for the consumer once they are,
+	// which bounds the memory in use.
+	Buffer int
+}
+
+// NDJSONResult is the document of a line of NDJSON, or its error.
+type NDJSONResult struct {
+	// Line is the number of the line, 0 for the failure of the reader
+	Line int
+	Json *Json
+	// Err is a *LineError for a bad line
+	Err error
+}
+
+type ndjsonJob struct {
+	seq   int
+	line  []byte
+	start Position
+}
+
+type ndjsonDone struct {
+	seq    int
+	result NDJSONResult
+}
+
+// ParseNDJSONParallel reads the NDJSON of r and parses its lines on several goroutines,
+// each with a Tokenizer and Parser of its own. The results are sent on the returned
+// channel, which is closed after the last one. Blank lines are skipped as by NDJSONReader.
+//
+// A failure of the reader comes last, and so does exceeding TokenizerOptions.MaxInputSize,
+// which ends the reading. Once ctx is done, the channel is closed without any more results,
+// and ctx.Err() tells why.
+func ParseNDJSONParallel(ctx context.Context, r io.Reader, options NDJSONParallelOptions) <-chan NDJSONResult {
+	workers := options.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	buffer := options.Buffer
+	if buffer <= 0 {
+		buffer = 16 * workers
+	}
+
+	rd := NewNDJSONReader(r)
+	rd.SourceName = options.SourceName
+	rd.TokenizerOptions = options.TokenizerOptions
+	rd.Options = options.Options
+
+	out := make(chan NDJSONResult)
+	jobs := make(chan ndjsonJob)
+	results := make(chan ndjsonDone, workers)
+	// slots holds a token for every line read whose result has not been taken yet
+	slots := make(chan struct{}, buffer)
+	var readErr error
+
+	go func() {
+		defer close(jobs)
+		for seq := 0; ; seq++ {
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			line, start, err := rd.nextLine()
+			if err != nil {
+				if err != io.EOF {
+					readErr = err
+				}
+				return
+			}
+			select {
+			case jobs <- ndjsonJob{seq: seq, line: line, start: start}:
+			case <-ctx.Done():
+				return
+			}
+			if rd.exceeds(line, start) {
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				result := NDJSONResult{Line: job.start.Line}
+				if js, err := rd.parse(job.line, job.start); err != nil {
+					result.Err = &LineError{Line: job.start.Line, Err: err}
+				} else {
+					result.Json = js
+				}
+				select {
+				case results <- ndjsonDone{seq: job.seq, result: result}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	go func() {
+		defer close(out)
+		send := func(result NDJSONResult) bool {
+			select {
+			case out <- result:
+				<-slots
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		// pending holds the results done ahead of their turn
+		pending := map[int]NDJSONResult{}
+		next := 0
+		for done := range results {
+			if !options.Ordered {
+				if !send(done.result) {
+					return
+				}
+				continue
+			}
+			pending[done.seq] = done.result
+			for result, ok := pending[next]; ok; result, ok = pending[next] {
+				delete(pending, next)
+				next++
+				if !send(result) {
+					return
+				}
+			}
+		}
+		// with ctx still going, every worker saw jobs closed, so the reader is done with readErr
+		if ctx.Err() == nil && readErr != nil {
+			send(NDJSONResult{Err: readErr})
+		}
+	}()
+
+	return out
+}
+
+// ParseNDJSONAll parses the NDJSON of r like ParseNDJSONParallel, in order, and returns
+// the documents of all its lines. On a bad line, it stops and returns the error of the
+// first one, the same however the lines are scheduled.
+func ParseNDJSONAll(ctx context.Context, r io.Reader, options NDJSONParallelOptions) ([]*Json, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	options.Ordered = true
+
+	var docs []*Json
+	for result := range ParseNDJSONParallel(ctx, r, options) {
+		if result.Err != nil {
+			return nil, result.Err
+		}
+		docs = append(docs, result.Json)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return docs, nil
+}
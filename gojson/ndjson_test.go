@@ -0,0 +1,277 @@
+package gojson
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+func TestNDJSONReader(t *testing.T) {
+	input := "\xEF\xBB\xBF{\"a\": 1}\r\n\n  \t\r\n[1, 2]\n\"x\"\n{\"b\": tru}\n[3, 4\n{\"c\": {}}"
+
+	readAll := func(r *NDJSONReader) ([]interface{}, []int, []error) {
+		var values []interface{}
+		var lines []int
+		var errs []error
+		for {
+			js, err := r.Read()
+			if err == io.EOF {
+				return values, lines, errs
+			}
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			value, _ := js.Value()
+			values = append(values, value)
+			lines = append(lines, r.Line())
+		}
+	}
+	want := []interface{}{
+		map[string]interface{}{"a": float64(1)},
+		[]interface{}{float64(1), float64(2)},
+		"x",
+		map[string]interface{}{"c": map[string]interface{}{}},
+	}
+
+	// report
+	r := NewNDJSONReader(iotest.OneByteReader(strings.NewReader(input)))
+	r.SourceName = "feed.ndjson"
+	values, lines, errs := readAll(r)
+	assert.Equal(t, want, values)
+	assert.Equal(t, []int{1, 4, 5, 8}, lines)
+	if assert.Len(t, errs, 2) {
+		var lineErr *LineError
+		assert.True(t, errors.As(errs[0], &lineErr))
+		assert.Equal(t, 6, lineErr.Line)
+		assert.True(t, errors.Is(errs[0], ErrUndefinedKeyword))
+		assert.EqualError(t, errs[0], "feed.ndjson:6:7: [t-UndefinedKeywordError] undefined keyword: `tru`")
+		// positions are those in the whole input, without the byte order mark
+		assert.Equal(t, Position{Offset: 33, Rune: 33, Line: 6, Column: 7}, errs[0].(*LineError).Err.(*TokenizerError).Start)
+		assert.Equal(t, "feed.ndjson:6:7: UndefinedKeywordError: undefined keyword\n"+
+			" 6 | {\"b\": tru}\n"+
+			"   |       ^~~", FormatError([]byte(input), errs[0]))
+		assert.True(t, errors.Is(errs[1], ErrUnexpectedEOF))
+		assert.Equal(t, 7, errs[1].(*LineError).Line)
+	}
+
+	// skip
+	r = NewNDJSONReader(strings.NewReader(input))
+	r.BadLines = BadLinesSkip
+	values, _, errs = readAll(r)
+	assert.Equal(t, want, values)
+	assert.Nil(t, errs)
+	assert.Nil(t, r.Errors)
+
+	// collect
+	r = NewNDJSONReader(strings.NewReader(input))
+	r.BadLines = BadLinesCollect
+	values, _, errs = readAll(r)
+	assert.Equal(t, want, values)
+	assert.Nil(t, errs)
+	if assert.Len(t, r.Errors, 2) {
+		assert.Equal(t, 6, r.Errors[0].Line)
+		assert.Equal(t, 7, r.Errors[1].Line)
+	}
+}
+
+func TestNDJSONReader_Limits(t *testing.T) {
+	r := NewNDJSONReader(strings.NewReader("[1]\n[2, 3, 4, 5, 6, 7]\n[8]\n"))
+	r.TokenizerOptions.MaxInputSize = 10
+	r.BadLines = BadLinesSkip
+	js, err := r.Read()
+	assert.NoError(t, err)
+	assert.NotNil(t, js)
+	_, err = r.Read()
+	assert.True(t, errors.Is(err, ErrLimitExceeded))
+	_, err2 := r.Read()
+	assert.Equal(t, err, err2)
+}
+
+func TestNDJSONReader_ReaderError(t *testing.T) {
+	boom := errors.New("boom")
+	r := NewNDJSONReader(io.MultiReader(strings.NewReader("[1]\n[2]"), iotest.ErrReader(boom)))
+	_, err := r.Read()
+	assert.NoError(t, err)
+	_, err = r.Read()
+	assert.NoError(t, err)
+	_, err = r.Read()
+	assert.Equal(t, boom, err)
+}
+
+func TestNDJSONWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewNDJSONWriter(&buf)
+	for _, json := range []string{"{\"a\": [1, 2.50, {}],\n \"b\": \"x\\ty\"}", "[]", " \"\\u00e9\\u2028\" "} {
+		js, err := NewParser(NewTokenizer(json).MustTokenize()).Parse()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.NoError(t, w.Write(js))
+	}
+	assert.Equal(t, "{\"a\":[1,2.50,{}],\"b\":\"x\\ty\"}\n[]\n\"é\\u2028\"\n", buf.String())
+
+	// and back
+	r := NewNDJSONReader(&buf)
+	for i := 0; i < 3; i++ {
+		_, err := r.Read()
+		assert.NoError(t, err)
+	}
+	_, err := r.Read()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestJson_Compact(t *testing.T) {
+	var tests = []struct {
+		json     string
+		options  TokenizerOptions
+		expected string
+	}{
+		{"{ \"a\" : [ 1 , -0.5e10 , true , false , null ] }", TokenizerOptions{}, `{"a":[1,-0.5e10,true,false,null]}`},
+		{"// c\n[\"\\u0001\\\"\\\\/\"]", TokenizerOptions{Comments: true}, `["\u0001\"\\/"]`},
+		{"{a: 'it\\'s', b: 0x1F, c: .5, d: +1, e: 5.}", TokenizerOptions{Dialect: DialectJSON5}, `{"a":"it's","b":31,"c":0.5,"d":1,"e":5}`},
+		{"[\"\\ud800\"]", TokenizerOptions{LoneSurrogates: LoneSurrogateKeep}, `["\ud800"]`},
+	}
+
+	for _, tt := range tests {
+		tk := NewTokenizer(tt.json)
+		tk.Options = tt.options
+		ps := NewParser(tk.MustTokenize())
+		ps.Options.Dialect = tt.options.Dialect
+		js, err := ps.Parse()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := js.Compact()
+		assert.NoError(t, err, tt.json)
+		assert.Equal(t, tt.expected, string(b), tt.json)
+	}
+
+	tk := NewTokenizer("[Infinity]")
+	tk.Options.Dialect = DialectJSON5
+	js, _ := NewParser(tk.MustTokenize()).Parse()
+	_, err := js.Compact()
+	assert.Error(t, err)
+}
+
+// ndjsonLines makes n lines of NDJSON, with a bad one at every line number in bad.
+func ndjsonLines(n int, bad ...int) string {
+	var b strings.Builder
+	for i := 1; i <= n; i++ {
+		isBad := false
+		for _, line := range bad {
+			isBad = isBad || line == i
+		}
+		if isBad {
+			fmt.Fprintf(&b, "{\"n\": %v,}\n", i)
+		} else {
+			fmt.Fprintf(&b, "{\"n\": %v, \"s\": \"line %v\"}\n", i, i)
+		}
+	}
+	return b.String()
+}
+
+func TestParseNDJSONParallel(t *testing.T) {
+	input := ndjsonLines(500, 123, 321) + "\n\n"
+
+	for _, ordered := range []bool{true, false} {
+		var lines []int
+		var bad []int
+		for result := range ParseNDJSONParallel(context.Background(), strings.NewReader(input), NDJSONParallelOptions{Workers: 4, Ordered: ordered, Buffer: 8}) {
+			if result.Err != nil {
+				assert.Equal(t, result.Line, result.Err.(*LineError).Line)
+				assert.True(t, errors.Is(result.Err, ErrSyntax))
+				bad = append(bad, result.Line)
+				continue
+			}
+			assert.Equal(t, float64(result.Line), result.Json.Node().Get("n").Val.MustLoadAsFloat64())
+			lines = append(lines, result.Line)
+		}
+		if !ordered {
+			sort.Ints(lines)
+			sort.Ints(bad)
+		}
+		assert.Len(t, lines, 498)
+		assert.True(t, sort.IntsAreSorted(lines))
+		assert.Equal(t, []int{123, 321}, bad)
+	}
+
+	// the first bad line is the one reported, however the lines are scheduled
+	for i := 0; i < 10; i++ {
+		_, err := ParseNDJSONAll(context.Background(), strings.NewReader(input), NDJSONParallelOptions{Workers: 8})
+		if assert.Error(t, err) {
+			assert.Equal(t, 123, err.(*LineError).Line)
+		}
+	}
+	docs, err := ParseNDJSONAll(context.Background(), strings.NewReader(ndjsonLines(50)), NDJSONParallelOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, docs, 50)
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	atomic.AddInt64(&r.n, int64(n))
+	return n, err
+}
+
+func TestParseNDJSONParallel_Backpressure(t *testing.T) {
+	input := ndjsonLines(1000)
+	cr := &countingReader{r: iotest.OneByteReader(strings.NewReader(input))}
+	ctx, cancel := context.WithCancel(context.Background())
+	results := ParseNDJSONParallel(ctx, cr, NDJSONParallelOptions{Workers: 2, Buffer: 4})
+
+	<-results
+	time.Sleep(50 * time.Millisecond)
+	// the lines taken and the buffered ones, and one being read
+	assert.Less(t, atomic.LoadInt64(&cr.n), int64(len(input)/100))
+
+	// cancelling closes the channel
+	cancel()
+	for range results {
+	}
+}
+
+func TestParseNDJSONParallel_Errors(t *testing.T) {
+	boom := errors.New("boom")
+	r := io.MultiReader(strings.NewReader(ndjsonLines(20)), iotest.ErrReader(boom))
+	var last NDJSONResult
+	n := 0
+	for result := range ParseNDJSONParallel(context.Background(), r, NDJSONParallelOptions{}) {
+		last = result
+		n++
+	}
+	assert.Equal(t, 21, n)
+	assert.Equal(t, NDJSONResult{Err: boom}, last)
+
+	// the input limit ends the reading
+	input := ndjsonLines(20)
+	var bad []int
+	n = 0
+	for result := range ParseNDJSONParallel(context.Background(), strings.NewReader(input), NDJSONParallelOptions{
+		Ordered:          true,
+		TokenizerOptions: TokenizerOptions{MaxInputSize: 100},
+	}) {
+		if result.Err != nil {
+			assert.True(t, errors.Is(result.Err, ErrLimitExceeded))
+			bad = append(bad, result.Line)
+		}
+		n++
+	}
+	assert.Equal(t, []int{5}, bad)
+	assert.Equal(t, 5, n)
+}
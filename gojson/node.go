@@ -48,4 +48,41 @@ type Node struct {
 	Children *[]Node
 	Key      string
 	Val      *Token
+
+	// Start <= Node < End are byte offsets, set by the Parser
+	Start int
+	End   int
+
+	// LeadingComments are the comments right before the node and TrailingComments
+	// the ones behind it on the same line, with ParserOptions.KeepComments.
+	// The comments before the closing bracket of an object or array trail its last
+	// child, or the object or array itself when it is empty.
+	LeadingComments  []Token
+	TrailingComments []Token
+}
+
+// Get returns the value of the member named key of an object, the last one
+// if there are several, or nil if there is none.
+func (nd *Node) Get(key string) *Node {
+	values := nd.GetAll(key)
+	if len(values) == 0 {
+		return nil
+	}
+	return values[len(values)-1]
+}
+
+// GetAll returns the values of every member named key of an object, in order.
+// There are several only with DuplicateKeysKeepAll.
+func (nd *Node) GetAll(key string) []*Node {
+	if nd.Type != NDObject || nd.Children == nil {
+		return nil
+	}
+	var values []*Node
+	children := *nd.Children
+	for i := range children {
+		if children[i].Key == key {
+			values = append(values, &(*children[i].Children)[0])
+		}
+	}
+	return values
 }
@@ -0,0 +1,89 @@
+package gojson
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// OrderedMap is a JSON object that keeps its keys in order.
+// Its values are mapped like those of Json.Map, except that objects are *OrderedMap too.
+type OrderedMap struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func NewOrderedMap() *OrderedMap {
+	return &OrderedMap{
+		values: map[string]interface{}{},
+	}
+}
+
+// Get returns the value of key and whether there is one.
+func (m *OrderedMap) Get(key string) (interface{}, bool) {
+	value, ok := m.values[key]
+	return value, ok
+}
+
+// Set sets the value of key. A new key goes last, an existing one keeps its place.
+func (m *OrderedMap) Set(key string, value interface{}) {
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = value
+}
+
+// Delete removes key, if there is one.
+func (m *OrderedMap) Delete(key string) {
+	if _, ok := m.values[key]; !ok {
+		return
+	}
+	delete(m.values, key)
+	for i, k := range m.keys {
+		if k == key {
+			m.keys = append(m.keys[:i], m.keys[i+1:]...)
+			break
+		}
+	}
+}
+
+// Keys returns the keys in order.
+func (m *OrderedMap) Keys() []string {
+	return append([]string{}, m.keys...)
+}
+
+func (m *OrderedMap) Len() int {
+	return len(m.keys)
+}
+
+// Range calls f for every key and value in order, until f returns false.
+func (m *OrderedMap) Range(f func(key string, value interface{}) bool) {
+	for _, key := range m.keys {
+		if !f(key, m.values[key]) {
+			return
+		}
+	}
+}
+
+// MarshalJSON writes the object with its keys in order.
+func (m *OrderedMap) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range m.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		v, err := json.Marshal(m.values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
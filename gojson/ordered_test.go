@@ -0,0 +1,44 @@
+package gojson
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestOrderedMap(t *testing.T) {
+	m := NewOrderedMap()
+	m.Set("b", 1)
+	m.Set("a", 2)
+	m.Set("c", 3)
+	m.Set("b", 4)
+	assert.Equal(t, []string{"b", "a", "c"}, m.Keys())
+	assert.Equal(t, 3, m.Len())
+
+	value, ok := m.Get("b")
+	assert.True(t, ok)
+	assert.Equal(t, 4, value)
+	_, ok = m.Get("x")
+	assert.False(t, ok)
+
+	m.Delete("a")
+	m.Delete("x")
+	assert.Equal(t, []string{"b", "c"}, m.Keys())
+	m.Set("a", 5)
+	assert.Equal(t, []string{"b", "c", "a"}, m.Keys())
+
+	var keys []string
+	m.Range(func(key string, value interface{}) bool {
+		keys = append(keys, key)
+		return key != "c"
+	})
+	assert.Equal(t, []string{"b", "c"}, keys)
+
+	// Keys hands out a copy
+	m.Keys()[0] = "z"
+	assert.Equal(t, []string{"b", "c", "a"}, m.Keys())
+
+	b, err := json.Marshal(m)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"b":4,"c":3,"a":5}`, string(b))
+}
@@ -2,8 +2,26 @@ package gojson
 
 import (
 	"fmt"
+	"strconv"
 )
 
+// DuplicateKeyPolicy decides what happens to an object member whose key an earlier member already has.
+type DuplicateKeyPolicy int
+
+const (
+	// DuplicateKeysKeepAll keeps every member, Node.GetAll finds them and the mappings use the last one.
+	DuplicateKeysKeepAll DuplicateKeyPolicy = iota
+	// DuplicateKeysError rejects the object with a DuplicateKeyError.
+	DuplicateKeysError
+	// DuplicateKeysFirstWins drops the later members.
+	DuplicateKeysFirstWins
+	// DuplicateKeysLastWins puts the value of the later member in place of the earlier one.
+	DuplicateKeysLastWins
+)
+
+// DefaultMaxDepth is the nesting depth a Parser allows unless ParserOptions.MaxDepth says otherwise.
+const DefaultMaxDepth = 1000
+
 func NewParser(tokens *[]Token) *Parser {
 	return &Parser{
 		Tokens: *tokens,
@@ -12,22 +30,270 @@ func NewParser(tokens *[]Token) *Parser {
 	}
 }
 
+// NewStreamParser parses the tokens handed out by src, pulling them only as they are needed.
+func NewStreamParser(src TokenSource) *Parser {
+	return &Parser{
+		Pos:    0,
+		Depth:  0,
+		source: src,
+	}
+}
+
+type ParserOptions struct {
+	// Dialect DialectJSON5 accepts TIdentifier keys and trailing commas, it should match the tokenizer's
+	Dialect Dialect
+	// AllowTrailingComma accepts a comma behind the last member or element
+	AllowTrailingComma bool
+	DuplicateKeys      DuplicateKeyPolicy
+	// MaxDepth is how deep objects and arrays may nest, 0 means DefaultMaxDepth and a negative value no limit
+	MaxDepth int
+
+	// Limits on untrusted input, 0 means no limit. Exceeding one is a LimitError.
+	MaxObjectMembers int
+	MaxArrayElements int
+	// MaxNodes limits the nodes of the whole document: objects, arrays, pairs and values
+	MaxNodes int
+	// KeepComments attaches TComment tokens to the nodes around them instead of dropping them
+	KeepComments bool
+}
+
 type Parser struct {
+	// Tokens holds the tokens to parse.
+	// With a TokenSource, it is only a window of the recently pulled ones.
 	Tokens []Token
 	Pos    int
-	Depth  int
+	// Depth is the number of objects and arrays open at Pos
+	Depth int
+	// SourceName is the file name or the like reported in errors
+	SourceName string
+
+	Options ParserOptions
+
+	source    TokenSource
+	sourceErr error
+	// comments are the skipped comments not yet attached to a node
+	comments []Token
+	// path leads from the root to the value being parsed
+	path []pathSegment
+	// nodes counts the nodes made so far
+	nodes int
+
+	// state of ParseRecover: the problems found, whether a limit ended the parse
+	// and how many objects and arrays were open then, and their closing brackets
+	diagnostics []Diagnostic
+	aborted     bool
+	abortedAt   int
+	closers     []TokenType
+}
+
+// pathSegment is an array index, or an object key when index is -1.
+type pathSegment struct {
+	key   string
+	index int
+}
+
+// pathString formats the path to the value being parsed, such as $.a[0]["b c"].
+func (p *Parser) pathString() string {
+	path := []byte{'$'}
+	for _, seg := range p.path {
+		switch {
+		case seg.index >= 0:
+			path = append(path, '[')
+			path = strconv.AppendInt(path, int64(seg.index), 10)
+			path = append(path, ']')
+		case isPathName(seg.key):
+			path = append(path, '.')
+			path = append(path, seg.key...)
+		default:
+			path = append(path, '[')
+			path = strconv.AppendQuote(path, seg.key)
+			path = append(path, ']')
+		}
+	}
+	return string(path)
 }
 
+// isPathName reports whether key can be written as .key in a path.
+func isPathName(key string) bool {
+	for i, r := range key {
+		if !isIdentifierLetter(r, i == 0) {
+			return false
+		}
+	}
+	return key != ""
+}
+
+// enter opens an object or array at token, failing once that nests deeper than MaxDepth.
+func (p *Parser) enter(token Token) error {
+	maxDepth := p.Options.MaxDepth
+	if maxDepth == 0 {
+		maxDepth = DefaultMaxDepth
+	}
+	if maxDepth > 0 && p.Depth >= maxDepth {
+		// the path is left out of the message, it grows with the depth
+		return &ParserError{
+			ErrorType:    DepthLimitError,
+			ErrorMessage: fmt.Sprintf("nesting depth exceeds %v", maxDepth),
+			Start:        p.position(token.Start),
+			End:          p.position(token.End),
+			Path:         p.pathString(),
+			FoundType:    token.Type,
+		}
+	}
+	p.Depth++
+	return nil
+}
+
+// addNode counts the node about to be made at token against MaxNodes.
+func (p *Parser) addNode(token Token) error {
+	p.nodes++
+	if max := p.Options.MaxNodes; max > 0 && p.nodes > max {
+		return p.limitError(token, fmt.Sprintf("document exceeds the limit of %v nodes", max))
+	}
+	return nil
+}
+
+// limitError reports the limit msg describes as exceeded at token.
+// Like for enter, the path is only in Path.
+func (p *Parser) limitError(token Token, msg string) *ParserError {
+	return &ParserError{
+		ErrorType:    LimitError,
+		ErrorMessage: msg,
+		Start:        p.position(token.Start),
+		End:          p.position(token.End),
+		Path:         p.pathString(),
+		FoundType:    token.Type,
+	}
+}
+
+// Token returns the current token, skipping over comments.
 func (p *Parser) Token() Token {
-	return p.Tokens[p.Pos]
+	p.pull(0)
+	for p.at(p.Pos).Type == TComment {
+		if p.Options.KeepComments {
+			p.comments = append(p.comments, p.at(p.Pos))
+		}
+		p.Pos++
+		p.pull(0)
+	}
+	return p.at(p.Pos)
 }
 
+// NextToken returns the token behind the current one, skipping over comments.
 func (p *Parser) NextToken() Token {
-	return p.Tokens[p.Pos+1]
+	p.Token()
+	for ahead := 1; ; ahead++ {
+		p.pull(ahead)
+		if token := p.at(p.Pos + ahead); token.Type != TComment {
+			return token
+		}
+	}
+}
+
+// takeComments hands over the pending comments, which lead the node about to be parsed.
+func (p *Parser) takeComments() []Token {
+	comments := p.comments
+	p.comments = nil
+	return comments
+}
+
+// attachTrailing hands the pending comments on the line nd ends on over to nd.
+func (p *Parser) attachTrailing(nd *Node) {
+	p.Token()
+	n := 0
+	for n < len(p.comments) && p.comments[n].src.line(p.comments[n].Start) == p.line(nd.End) {
+		n++
+	}
+	nd.TrailingComments = append(nd.TrailingComments, p.comments[:n]...)
+	p.comments = p.comments[n:]
+}
+
+// attachDangling hands the comments left before the closing bracket of nd
+// over to its last child, or to nd itself when it is empty.
+func (p *Parser) attachDangling(nd *Node) {
+	if len(p.comments) == 0 {
+		return
+	}
+	if children := *nd.Children; len(children) > 0 {
+		nd = &children[len(children)-1]
+	}
+	nd.TrailingComments = append(nd.TrailingComments, p.takeComments()...)
+}
+
+// pull makes sure the token ahead of Pos has been read from the source.
+// A failure of the source is kept in sourceErr, its token is turned into TUnknown
+// and every later one into TEof.
+func (p *Parser) pull(ahead int) {
+	if p.source == nil {
+		return
+	}
+
+	// drop the consumed tokens, keeping one for PrevToken
+	if p.Pos > 1 && p.Pos >= len(p.Tokens)/2 {
+		n := copy(p.Tokens, p.Tokens[p.Pos-1:])
+		p.Tokens = p.Tokens[:n]
+		p.Pos = 1
+	}
+
+	for len(p.Tokens) <= p.Pos+ahead {
+		if p.sourceErr != nil {
+			last := p.Tokens[len(p.Tokens)-1]
+			p.Tokens = append(p.Tokens, Token{Type: TEof, Start: last.End, End: last.End, src: last.src})
+			continue
+		}
+		token, err := p.source.Next()
+		if err != nil {
+			p.sourceErr = err
+			token.Type = TUnknown
+		}
+		p.Tokens = append(p.Tokens, token)
+	}
 }
 
 func (p *Parser) PrevToken() Token {
-	return p.Tokens[p.Pos-1]
+	return p.at(p.Pos - 1)
+}
+
+// at returns Tokens[i]. Off the end of Tokens, which may lack its TEof,
+// it returns a TEof token at the end of the last token.
+func (p *Parser) at(i int) Token {
+	if 0 <= i && i < len(p.Tokens) {
+		return p.Tokens[i]
+	}
+	var last Token
+	if len(p.Tokens) > 0 {
+		last = p.Tokens[len(p.Tokens)-1]
+	}
+	return Token{Type: TEof, Start: last.End, End: last.End, src: last.src}
+}
+
+// position works out the Position of the byte offset o from a token around it.
+func (p *Parser) position(o int) Position {
+	return p.sourceAt(o).position(o)
+}
+
+// line works out the line number of the byte offset o like position, without counting runes.
+func (p *Parser) line(o int) int {
+	return p.sourceAt(o).line(o)
+}
+
+// sourceAt returns the source of a token around the byte offset o. It is nil for tokens made
+// by hand, or when the tokens around o have left the window of a TokenSource.
+func (p *Parser) sourceAt(o int) *source {
+	for i := len(p.Tokens) - 1; i >= 0; i-- {
+		if p.Tokens[i].src.covers(o) {
+			return p.Tokens[i].src
+		}
+	}
+	return nil
+}
+
+// endOrSyntaxError is the ErrorType for finding a token of type found where it does not belong.
+func endOrSyntaxError(found TokenType) ErrorType {
+	if found == TEof {
+		return UnexpectedEndOfInputError
+	}
+	return SyntaxError
 }
 
 func (p *Parser) GoNext() {
@@ -42,50 +308,69 @@ func (p *Parser) IsValidToken() bool {
 	return p.Token().Type != TEof
 }
 
+// Parse parses a whole document: a single value of any kind, followed by nothing but TEof.
 func (p *Parser) Parse() (*Json, error) {
-	var nd *Node
-	var err error
-	var rootNodeType NodeType
-	for p.IsValidToken() {
-		if p.Token().Type == TLCurlyBracket {
-			nd, err = p.ParseObject()
-			if err != nil {
-				return nil, err
-			}
-			rootNodeType = NDObject
-		} else if p.Token().Type == TLSquareBracket {
-			nd, err = p.ParseArray()
-			if err != nil {
-				return nil, err
-			}
-			rootNodeType = NDArray
-		} else {
-			tk := p.Token()
-			return nil, &ParserError{
-				ErrorType:    SyntaxError,
-				ErrorMessage: fmt.Sprintf("expected `[` or `{`, but found `%v`", string(tk.Data)),
-				StartPos:     tk.StartPos,
-				EndPos:       tk.EndPos,
-				ExpectedType: []TokenType{TLSquareBracket, TLCurlyBracket},
-				FoundType:    tk.Type,
-			}
-		}
+	nd, err := p.ParseValue()
+	if err != nil {
+		return nil, p.sourceError(err)
+	}
+
+	if err := p.expectEnd(); err != nil {
+		return nil, err
 	}
-	j := NewJson(nd, rootNodeType)
+	nd.TrailingComments = append(nd.TrailingComments, p.takeComments()...)
+
+	j := NewJson(nd, nd.Type)
 	return j, nil
 }
 
+// expectEnd makes sure that nothing but TEof follows the document.
+func (p *Parser) expectEnd() error {
+	if tk := p.Token(); tk.Type != TEof {
+		return p.sourceError(&ParserError{
+			ErrorType:    SyntaxError,
+			ErrorMessage: fmt.Sprintf("expected the end of input, but found `%v`", string(tk.Raw())),
+			Start:        p.position(tk.Start),
+			End:          p.position(tk.End),
+			ExpectedType: []TokenType{TEof},
+			FoundType:    tk.Type,
+		})
+	}
+	return p.sourceErr
+}
+
+// sourceError prefers the failure of the token source over err,
+// which is most likely a consequence of it, and names the source in a *ParserError.
+func (p *Parser) sourceError(err error) error {
+	if p.sourceErr != nil {
+		return p.sourceErr
+	}
+	if psErr, ok := err.(*ParserError); ok {
+		psErr.SourceName = p.SourceName
+	}
+	return err
+}
+
 func (p *Parser) ParseObject() (*Node, error) {
+	start := p.Token().Start
+	leading := p.takeComments()
 	if p.Token().Type != TLCurlyBracket {
 		return nil, &ParserError{
-			ErrorType:    SyntaxError,
-			ErrorMessage: fmt.Sprintf("expected `{`, but found `%v`", string(p.Token().Data)),
-			StartPos:     p.Token().StartPos,
-			EndPos:       p.Token().EndPos,
+			ErrorType:    endOrSyntaxError(p.Token().Type),
+			ErrorMessage: fmt.Sprintf("expected `{`, but found `%v`", string(p.Token().Raw())),
+			Start:        p.position(p.Token().Start),
+			End:          p.position(p.Token().End),
 			ExpectedType: []TokenType{TLCurlyBracket},
 			FoundType:    p.Token().Type,
 		}
 	}
+	if err := p.enter(p.Token()); err != nil {
+		return nil, err
+	}
+	if err := p.addNode(p.Token()); err != nil {
+		return nil, err
+	}
+	defer func() { p.Depth-- }()
 	// consume '{'
 	p.GoNext()
 
@@ -96,14 +381,15 @@ func (p *Parser) ParseObject() (*Node, error) {
 
 	if p.Token().Type != TRCurlyBracket {
 		return nil, &ParserError{
-			ErrorType:    SyntaxError,
-			ErrorMessage: fmt.Sprintf("expected `}`, but found `%v`", string(p.Token().Data)),
-			StartPos:     p.Token().StartPos,
-			EndPos:       p.Token().EndPos,
+			ErrorType:    endOrSyntaxError(p.Token().Type),
+			ErrorMessage: fmt.Sprintf("expected `}`, but found `%v`", string(p.Token().Raw())),
+			Start:        p.position(p.Token().Start),
+			End:          p.position(p.Token().End),
 			ExpectedType: []TokenType{TRCurlyBracket},
 			FoundType:    p.Token().Type,
 		}
 	}
+	end := p.Token().End
 	// consume '}'
 	p.GoNext()
 
@@ -114,7 +400,7 @@ func (p *Parser) ParseObject() (*Node, error) {
 	//if p.Token().Type != TEof {
 	//	return nil, &ParserError{
 	//		ErrorType:    SyntaxError,
-	//		ErrorMessage: fmt.Sprintf("expected `EOF`, but found `%v`", string(p.Token().Data)),
+	//		ErrorMessage: fmt.Sprintf("expected `EOF`, but found `%v`", string(p.Token().Raw())),
 	//		Tokens:       nil,
 	//		StartPos:     p.Token().StartPos,
 	//		EndPos:       p.Token(.EndPos,
@@ -124,54 +410,175 @@ func (p *Parser) ParseObject() (*Node, error) {
 	//}
 
 	obj := NewNode(NDObject, members, "", nil)
+	obj.Start, obj.End = start, end
+	obj.LeadingComments = leading
+	p.attachDangling(obj)
 
 	return obj, nil
 }
 
+// ParseMember parses the pairs of an object up to its closing `}`,
+// separated by exactly one comma each.
 func (p *Parser) ParseMember() (*[]Node, error) {
 	var member []Node
+	// seen locates the members by key, unless all members are kept anyway
+	var seen map[string]keySeen
+
+	for p.Token().Type != TRCurlyBracket {
+		if max := p.Options.MaxObjectMembers; max > 0 && len(member) >= max {
+			return nil, p.limitError(p.Token(), fmt.Sprintf("object exceeds the limit of %v members", max))
+		}
+		first := p.Token()
+		pair, err := p.ParsePair()
+		if err != nil {
+			return nil, err
+		}
+		last := p.PrevToken()
+
+		more, err := p.parseSeparator(TRCurlyBracket, pair)
+		if err != nil {
+			return nil, err
+		}
 
-ValueLoop:
-	for p.IsValidToken() {
-		token := p.Token()
-		switch token.Type {
-		case TString:
-			pair, err := p.ParsePair()
-			if err != nil {
-				return nil, nil
+		if prev, ok := seen[pair.Key]; ok {
+			switch p.Options.DuplicateKeys {
+			case DuplicateKeysError:
+				return nil, p.duplicateKeyError(pair.Key, first, last, prev)
+			case DuplicateKeysLastWins:
+				member[prev.index] = *pair
+				seen[pair.Key] = keySeen{index: prev.index, first: first, last: last}
+			}
+		} else {
+			if p.Options.DuplicateKeys != DuplicateKeysKeepAll {
+				if seen == nil {
+					seen = map[string]keySeen{}
+				}
+				seen[pair.Key] = keySeen{index: len(member), first: first, last: last}
 			}
 			member = append(member, *pair)
-		case TComma:
-			p.GoNext()
-			continue
-		default:
-			break ValueLoop
+		}
+
+		if !more {
+			break
 		}
 	}
 
 	return &member, nil
 }
 
+// keySeen is where a key is defined in an object, the index of its member
+// and the first and last token of it. Unlike the offsets of the member, the
+// tokens still locate it once they have left the window of a TokenSource.
+type keySeen struct {
+	index int
+	first Token
+	last  Token
+}
+
+// duplicateKeyError reports the member from first to last, repeating the key defined at prev.
+func (p *Parser) duplicateKeyError(key string, first Token, last Token, prev keySeen) *ParserError {
+	prevStart := prev.first.position(prev.first.Start)
+	return &ParserError{
+		ErrorType:    DuplicateKeyError,
+		ErrorMessage: fmt.Sprintf("duplicate key %q, first defined at %v", key, prevStart),
+		Start:        first.position(first.Start),
+		End:          last.position(last.End),
+		Path:         p.pathString(),
+		PrevStart:    prevStart,
+		PrevEnd:      prev.last.position(prev.last.End),
+	}
+}
+
+// parseSeparator consumes the comma behind the item nd, if any, and reports
+// whether another item follows it or the closing bracket does.
+func (p *Parser) parseSeparator(closing TokenType, nd *Node) (bool, error) {
+	token := p.Token()
+	if token.Type == closing {
+		return false, nil
+	}
+	if token.Type != TComma {
+		return false, &ParserError{
+			ErrorType:    endOrSyntaxError(token.Type),
+			ErrorMessage: fmt.Sprintf("expected `,` or `%v`, but found `%v`", closingBracket(closing), string(token.Raw())),
+			Start:        p.position(token.Start),
+			End:          p.position(token.End),
+			ExpectedType: []TokenType{TComma, closing},
+			FoundType:    token.Type,
+		}
+	}
+	// consume ','
+	p.GoNext()
+	if nd != nil {
+		p.attachTrailing(nd)
+	}
+
+	if p.Token().Type == closing && !p.Options.AllowTrailingComma && p.Options.Dialect != DialectJSON5 {
+		return false, &ParserError{
+			ErrorType:    SyntaxError,
+			ErrorMessage: fmt.Sprintf("trailing comma is not allowed before `%v`", closingBracket(closing)),
+			Start:        p.position(token.Start),
+			End:          p.position(token.End),
+			ExpectedType: []TokenType{closing},
+			FoundType:    TComma,
+		}
+	}
+	return p.Token().Type != closing, nil
+}
+
+func closingBracket(typ TokenType) string {
+	if typ == TRCurlyBracket {
+		return "}"
+	}
+	return "]"
+}
+
 func (p *Parser) ParsePair() (*Node, error) {
 	tkKey := p.Token()
-	if tkKey.Type != TString {
-		return nil, &ParserError{
-			ErrorType:    SyntaxError,
-			ErrorMessage: fmt.Sprintf("expected `TString`, but found `%v`", string(p.Token().Data)),
-			StartPos:     p.Token().StartPos,
-			EndPos:       p.Token().EndPos,
-			ExpectedType: []TokenType{TString},
+	leading := p.takeComments()
+	key, err := p.parseKey()
+	if err != nil {
+		return nil, err
+	}
+
+	p.path = append(p.path, pathSegment{key: key, index: -1})
+	tkVal, err := p.ParseValue()
+	p.path = p.path[:len(p.path)-1]
+	if err != nil {
+		return nil, err
+	}
+
+	pair := NewNode(NDPair, &[]Node{*tkVal}, key, nil)
+	pair.Start, pair.End = tkKey.Start, tkVal.End
+	pair.LeadingComments = leading
+
+	return pair, nil
+}
+
+// parseKey parses the key of a member and the colon behind it.
+func (p *Parser) parseKey() (string, error) {
+	tkKey := p.Token()
+	if tkKey.Type != TString && !p.isIdentifierKey(tkKey) {
+		expected := []TokenType{TString}
+		if p.Options.Dialect == DialectJSON5 {
+			expected = append(expected, TIdentifier)
+		}
+		return "", &ParserError{
+			ErrorType:    endOrSyntaxError(p.Token().Type),
+			ErrorMessage: fmt.Sprintf("expected `TString`, but found `%v`", string(p.Token().Raw())),
+			Start:        p.position(p.Token().Start),
+			End:          p.position(p.Token().End),
+			ExpectedType: expected,
 			FoundType:    p.Token().Type,
 		}
 	}
 	p.GoNext()
 
 	if tkColon := p.Token(); tkColon.Type != TColon {
-		return nil, &ParserError{
-			ErrorType:    SyntaxError,
-			ErrorMessage: fmt.Sprintf("expected `:`, but found `%v`", string(p.Token().Data)),
-			StartPos:     p.Token().StartPos,
-			EndPos:       p.Token().EndPos,
+		return "", &ParserError{
+			ErrorType:    endOrSyntaxError(p.Token().Type),
+			ErrorMessage: fmt.Sprintf("expected `:`, but found `%v`", string(p.Token().Raw())),
+			Start:        p.position(p.Token().Start),
+			End:          p.position(p.Token().End),
 			ExpectedType: []TokenType{TColon},
 			FoundType:    p.Token().Type,
 		}
@@ -179,28 +586,77 @@ func (p *Parser) ParsePair() (*Node, error) {
 	// consume ":"
 	p.GoNext()
 
-	tkVal, err := p.ParseValue()
+	if err := p.addNode(tkKey); err != nil {
+		return "", err
+	}
+	return p.loadString(keyToken(tkKey))
+}
+
+// loadString decodes the string or identifier token, a failure becomes a *ParserError.
+func (p *Parser) loadString(token Token) (string, error) {
+	s, err := token.LoadAsString()
 	if err != nil {
-		return nil, err
+		tkErr := err.(*TokenizerError)
+		return "", &ParserError{
+			ErrorType:    tkErr.ErrorType,
+			ErrorMessage: tkErr.ErrorMessage,
+			Start:        tkErr.Start,
+			End:          tkErr.End,
+			ExpectedType: []TokenType{TString},
+			FoundType:    token.Type,
+			Err:          tkErr,
+		}
 	}
+	return s, nil
+}
 
-	pair := NewNode(NDPair, &[]Node{*tkVal}, string(tkKey.Data), nil)
+// isIdentifierKey reports whether token is an unquoted key the dialect allows.
+// A JSON5 key is any identifier name, also true, false, null, Infinity and NaN,
+// which the tokenizer reads as values.
+func (p *Parser) isIdentifierKey(token Token) bool {
+	if p.Options.Dialect != DialectJSON5 {
+		return false
+	}
+	switch token.Type {
+	case TIdentifier, TTrue, TFalse, TNull:
+		return true
+	case TNumber:
+		raw := token.Raw()
+		return len(raw) > 0 && isIdentifierLetter(rune(raw[0]), true)
+	default:
+		return false
+	}
+}
 
-	return pair, nil
+// keyToken returns the key token as a TIdentifier when it is unquoted, for LoadAsString.
+func keyToken(token Token) Token {
+	if token.Type != TString {
+		token.Type = TIdentifier
+	}
+	return token
 }
 
 func (p *Parser) ParseArray() (*Node, error) {
 	token := p.Token()
+	start := token.Start
+	leading := p.takeComments()
 	if token.Type != TLSquareBracket {
 		return nil, &ParserError{
-			ErrorType:    SyntaxError,
-			ErrorMessage: fmt.Sprintf("expect `[`, but found %v", string(token.Data)),
-			StartPos:     p.Token().StartPos,
-			EndPos:       p.Token().EndPos,
+			ErrorType:    endOrSyntaxError(p.Token().Type),
+			ErrorMessage: fmt.Sprintf("expect `[`, but found %v", string(token.Raw())),
+			Start:        p.position(p.Token().Start),
+			End:          p.position(p.Token().End),
 			ExpectedType: []TokenType{TLSquareBracket},
 			FoundType:    p.Token().Type,
 		}
 	}
+	if err := p.enter(token); err != nil {
+		return nil, err
+	}
+	if err := p.addNode(token); err != nil {
+		return nil, err
+	}
+	defer func() { p.Depth-- }()
 	// consume '['
 	p.GoNext()
 
@@ -212,40 +668,49 @@ func (p *Parser) ParseArray() (*Node, error) {
 	token = p.Token()
 	if token.Type != TRSquareBracket {
 		return nil, &ParserError{
-			ErrorType:    SyntaxError,
-			ErrorMessage: fmt.Sprintf("expect `]`, but found %v", string(token.Data)),
-			StartPos:     p.Token().StartPos,
-			EndPos:       p.Token().EndPos,
+			ErrorType:    endOrSyntaxError(p.Token().Type),
+			ErrorMessage: fmt.Sprintf("expect `]`, but found %v", string(token.Raw())),
+			Start:        p.position(p.Token().Start),
+			End:          p.position(p.Token().End),
 			ExpectedType: []TokenType{TRSquareBracket},
 			FoundType:    p.Token().Type,
 		}
 	}
+	end := p.Token().End
 	// consume ']'
 	p.GoNext()
 
 	arr := NewNode(NDArray, el, "", nil)
+	arr.Start, arr.End = start, end
+	arr.LeadingComments = leading
+	p.attachDangling(arr)
 
 	return arr, nil
 }
 
+// ParseElement parses the values of an array up to its closing `]`,
+// separated by exactly one comma each.
 func (p *Parser) ParseElement() (*[]Node, error) {
 	var children []Node
 
-ValueLoop:
-	for p.IsValidToken() {
-		//fmt.Printf("[ParseElement @ %v-%v] `%v` (%v)\n", p.Token().StartPos, p.Token().EndPos, string(p.Token().Data), p.Token().Type.String())
-		switch p.Token().Type {
-		case TString, TNumber, TTrue, TFalse, TNull, TLCurlyBracket, TLSquareBracket:
-			nd, err := p.ParseValue()
-			if err != nil {
-				return nil, err
-			}
-			children = append(children, *nd)
-		case TComma:
-			p.GoNext()
-			continue
-		default:
-			break ValueLoop
+	for p.Token().Type != TRSquareBracket {
+		if max := p.Options.MaxArrayElements; max > 0 && len(children) >= max {
+			return nil, p.limitError(p.Token(), fmt.Sprintf("array exceeds the limit of %v elements", max))
+		}
+		p.path = append(p.path, pathSegment{index: len(children)})
+		nd, err := p.ParseValue()
+		p.path = p.path[:len(p.path)-1]
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, *nd)
+
+		more, err := p.parseSeparator(TRSquareBracket, &children[len(children)-1])
+		if err != nil {
+			return nil, err
+		}
+		if !more {
+			break
 		}
 	}
 
@@ -259,7 +724,12 @@ func (p *Parser) ParseValue() (*Node, error) {
 	switch token.Type {
 	// their kinds can access the value directly
 	case TString, TNumber, TTrue, TFalse, TNull:
+		if err := p.addNode(token); err != nil {
+			return nil, err
+		}
 		nd = NewNode(NDValue, nil, "", &token)
+		nd.Start, nd.End = token.Start, token.End
+		nd.LeadingComments = p.takeComments()
 		p.GoNext()
 	case TLCurlyBracket:
 		child, err := p.ParseObject()
@@ -273,6 +743,15 @@ func (p *Parser) ParseValue() (*Node, error) {
 			return nil, err
 		}
 		nd = child
+	default:
+		return nil, &ParserError{
+			ErrorType:    endOrSyntaxError(token.Type),
+			ErrorMessage: fmt.Sprintf("expected a value, but found `%v`", string(token.Raw())),
+			Start:        p.position(token.Start),
+			End:          p.position(token.End),
+			ExpectedType: []TokenType{TString, TNumber, TTrue, TFalse, TNull, TLCurlyBracket, TLSquareBracket},
+			FoundType:    token.Type,
+		}
 	}
 	return nd, nil
 }
@@ -1,15 +1,19 @@
 package gojson_test
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/stretchr/testify/assert"
 	"github.com/x0y14/gojson/gojson"
+	"strings"
 	"testing"
+	"testing/iotest"
 )
 
 func Setup(json string) *gojson.Parser {
 	tk := gojson.NewTokenizer(json)
-	ps := gojson.NewParser(tk.Tokenize())
+	ps := gojson.NewParser(tk.MustTokenize())
 	return ps
 }
 
@@ -17,6 +21,37 @@ func TestShowPos(t *testing.T) {
 	gojson.ShowPos("{\"msg\": \"hello\", \"in\": {\"age\": 20}}")
 }
 
+// linePos is the Position at offset in a single line of ASCII text
+func linePos(offset int) gojson.Position {
+	return gojson.Position{Offset: offset, Rune: offset, Line: 1, Column: offset + 1}
+}
+
+func span(nd *gojson.Node, start int, end int) *gojson.Node {
+	nd.Start, nd.End = start, end
+	return nd
+}
+
+func value(typ gojson.TokenType, data string, start int, end int) gojson.Node {
+	return *span(gojson.NewNode(gojson.NDValue, nil, "", gojson.NewToken(typ, data, start, end)), start, end)
+}
+
+// detached copies the tree of nd with its tokens made anew by NewToken,
+// to compare it with a tree of such tokens
+func detached(nd *gojson.Node) *gojson.Node {
+	cp := *nd
+	if nd.Val != nil {
+		cp.Val = gojson.NewToken(nd.Val.Type, string(nd.Val.Raw()), nd.Val.Start, nd.Val.End)
+	}
+	if nd.Children != nil {
+		children := make([]gojson.Node, len(*nd.Children))
+		for i := range children {
+			children[i] = *detached(&(*nd.Children)[i])
+		}
+		cp.Children = &children
+	}
+	return &cp
+}
+
 func TestParser_ParseArray(t *testing.T) {
 	var tests = []struct {
 		title  string
@@ -26,35 +61,35 @@ func TestParser_ParseArray(t *testing.T) {
 		{
 			"array only",
 			"[\"string\", 123, true, false, null]",
-			gojson.NewNode(gojson.NDArray, &[]gojson.Node{
-				*gojson.NewNode(gojson.NDValue, nil, "", gojson.NewToken(gojson.TString, "string", 1, 9)),
-				*gojson.NewNode(gojson.NDValue, nil, "", gojson.NewToken(gojson.TNumber, "123", 11, 14)),
-				*gojson.NewNode(gojson.NDValue, nil, "", gojson.NewToken(gojson.TTrue, "true", 16, 20)),
-				*gojson.NewNode(gojson.NDValue, nil, "", gojson.NewToken(gojson.TFalse, "false", 22, 27)),
-				*gojson.NewNode(gojson.NDValue, nil, "", gojson.NewToken(gojson.TNull, "null", 29, 33)),
-			}, "", nil),
+			span(gojson.NewNode(gojson.NDArray, &[]gojson.Node{
+				value(gojson.TString, "string", 1, 9),
+				value(gojson.TNumber, "123", 11, 14),
+				value(gojson.TTrue, "true", 16, 20),
+				value(gojson.TFalse, "false", 22, 27),
+				value(gojson.TNull, "null", 29, 33),
+			}, "", nil), 0, 34),
 		},
 		{
 			"array in array",
 			"[[\"hello\", \"world\"]]",
-			gojson.NewNode(gojson.NDArray, &[]gojson.Node{
-				*gojson.NewNode(gojson.NDArray, &[]gojson.Node{
-					*gojson.NewNode(gojson.NDValue, nil, "", gojson.NewToken(gojson.TString, "hello", 2, 9)),
-					*gojson.NewNode(gojson.NDValue, nil, "", gojson.NewToken(gojson.TString, "world", 11, 18)),
-				}, "", nil),
-			}, "", nil),
+			span(gojson.NewNode(gojson.NDArray, &[]gojson.Node{
+				*span(gojson.NewNode(gojson.NDArray, &[]gojson.Node{
+					value(gojson.TString, "hello", 2, 9),
+					value(gojson.TString, "world", 11, 18),
+				}, "", nil), 1, 19),
+			}, "", nil), 0, 20),
 		},
 		{
 			"array and other",
 			"[123, [\"hello\", \"world\"], \"321\"]",
-			gojson.NewNode(gojson.NDArray, &[]gojson.Node{
-				*gojson.NewNode(gojson.NDValue, nil, "", gojson.NewToken(gojson.TNumber, "123", 1, 4)),
-				*gojson.NewNode(gojson.NDArray, &[]gojson.Node{
-					*gojson.NewNode(gojson.NDValue, nil, "", gojson.NewToken(gojson.TString, "hello", 7, 14)),
-					*gojson.NewNode(gojson.NDValue, nil, "", gojson.NewToken(gojson.TString, "world", 16, 23)),
-				}, "", nil),
-				*gojson.NewNode(gojson.NDValue, nil, "", gojson.NewToken(gojson.TString, "321", 26, 31)),
-			}, "", nil),
+			span(gojson.NewNode(gojson.NDArray, &[]gojson.Node{
+				value(gojson.TNumber, "123", 1, 4),
+				*span(gojson.NewNode(gojson.NDArray, &[]gojson.Node{
+					value(gojson.TString, "hello", 7, 14),
+					value(gojson.TString, "world", 16, 23),
+				}, "", nil), 6, 24),
+				value(gojson.TString, "321", 26, 31),
+			}, "", nil), 0, 32),
 		},
 	}
 
@@ -64,7 +99,7 @@ func TestParser_ParseArray(t *testing.T) {
 		if err != nil {
 			t.Fatal(err)
 		}
-		res := assert.Equal(t, tt.expect, actual)
+		res := assert.Equal(t, tt.expect, detached(actual))
 		fmt.Printf("^^^ %v ^^^ \n  -> success: %v\n", tt.title, res)
 	}
 }
@@ -78,39 +113,39 @@ func TestParser_ParseObject(t *testing.T) {
 		{
 			"simple object",
 			"{\"msg\": \"hello\"}",
-			gojson.NewNode(gojson.NDObject, &[]gojson.Node{
-				*gojson.NewNode(gojson.NDPair, &[]gojson.Node{
-					*gojson.NewNode(gojson.NDValue, nil, "", gojson.NewToken(gojson.TString, "hello", 8, 15)),
-				}, "msg", nil),
-			}, "", nil),
+			span(gojson.NewNode(gojson.NDObject, &[]gojson.Node{
+				*span(gojson.NewNode(gojson.NDPair, &[]gojson.Node{
+					value(gojson.TString, "hello", 8, 15),
+				}, "msg", nil), 1, 15),
+			}, "", nil), 0, 16),
 		},
 		{
 			"object multi key",
 			"{\"msg\":\"hello\", \"age\": 20}",
-			gojson.NewNode(gojson.NDObject, &[]gojson.Node{
-				*gojson.NewNode(gojson.NDPair, &[]gojson.Node{
-					*gojson.NewNode(gojson.NDValue, nil, "", gojson.NewToken(gojson.TString, "hello", 7, 14)),
-				}, "msg", nil),
-				*gojson.NewNode(gojson.NDPair, &[]gojson.Node{
-					*gojson.NewNode(gojson.NDValue, nil, "", gojson.NewToken(gojson.TNumber, "20", 23, 25)),
-				}, "age", nil),
-			}, "", nil),
+			span(gojson.NewNode(gojson.NDObject, &[]gojson.Node{
+				*span(gojson.NewNode(gojson.NDPair, &[]gojson.Node{
+					value(gojson.TString, "hello", 7, 14),
+				}, "msg", nil), 1, 14),
+				*span(gojson.NewNode(gojson.NDPair, &[]gojson.Node{
+					value(gojson.TNumber, "20", 23, 25),
+				}, "age", nil), 16, 25),
+			}, "", nil), 0, 26),
 		},
 		{
 			"object in object",
 			"{\"msg\": \"hello\", \"in\": {\"age\": 20}}",
-			gojson.NewNode(gojson.NDObject, &[]gojson.Node{
-				*gojson.NewNode(gojson.NDPair, &[]gojson.Node{
-					*gojson.NewNode(gojson.NDValue, nil, "", gojson.NewToken(gojson.TString, "hello", 8, 15)),
-				}, "msg", nil),
-				*gojson.NewNode(gojson.NDPair, &[]gojson.Node{
-					*gojson.NewNode(gojson.NDObject, &[]gojson.Node{
-						*gojson.NewNode(gojson.NDPair, &[]gojson.Node{
-							*gojson.NewNode(gojson.NDValue, nil, "", gojson.NewToken(gojson.TNumber, "20", 31, 33)),
-						}, "age", nil),
-					}, "", nil),
-				}, "in", nil),
-			}, "", nil),
+			span(gojson.NewNode(gojson.NDObject, &[]gojson.Node{
+				*span(gojson.NewNode(gojson.NDPair, &[]gojson.Node{
+					value(gojson.TString, "hello", 8, 15),
+				}, "msg", nil), 1, 15),
+				*span(gojson.NewNode(gojson.NDPair, &[]gojson.Node{
+					*span(gojson.NewNode(gojson.NDObject, &[]gojson.Node{
+						*span(gojson.NewNode(gojson.NDPair, &[]gojson.Node{
+							value(gojson.TNumber, "20", 31, 33),
+						}, "age", nil), 24, 33),
+					}, "", nil), 23, 34),
+				}, "in", nil), 17, 34),
+			}, "", nil), 0, 35),
 		},
 	}
 	for _, tt := range tests {
@@ -119,7 +154,7 @@ func TestParser_ParseObject(t *testing.T) {
 		if err != nil {
 			t.Fatal(err)
 		}
-		res := assert.Equal(t, tt.expect, actual)
+		res := assert.Equal(t, tt.expect, detached(actual))
 		fmt.Printf("^^^ %v ^^^ \n  -> success: %v\n", tt.title, res)
 	}
 }
@@ -144,3 +179,672 @@ func TestParser_Parse(t *testing.T) {
 		assert.Equal(t, gojson.TEof, ps.Token().Type)
 	}
 }
+
+func TestParser_Parse_Root(t *testing.T) {
+	var tests = []struct {
+		json  string
+		root  gojson.NodeType
+		value interface{}
+	}{
+		{"\"text\"", gojson.NDValue, "text"},
+		{"42", gojson.NDValue, float64(42)},
+		{" -1.5e3 ", gojson.NDValue, -1.5e3},
+		{"true", gojson.NDValue, true},
+		{"false", gojson.NDValue, false},
+		{"null", gojson.NDValue, nil},
+		{"\n{\"a\": 1}\n", gojson.NDObject, map[string]interface{}{"a": float64(1)}},
+		{"[1]", gojson.NDArray, []interface{}{float64(1)}},
+	}
+
+	for _, tt := range tests {
+		js, err := Setup(tt.json).Parse()
+		if assert.NoError(t, err, tt.json) {
+			assert.Equal(t, tt.root, js.RootNodeType, tt.json)
+			value, err := js.Value()
+			assert.NoError(t, err, tt.json)
+			assert.Equal(t, tt.value, value, tt.json)
+		}
+	}
+
+	var garbage = []struct {
+		json   string
+		offset int
+		found  gojson.TokenType
+	}{
+		{"{}{}", 2, gojson.TLCurlyBracket},
+		{"[1] 2", 4, gojson.TNumber},
+		{"1 2", 2, gojson.TNumber},
+		{"\"a\",", 3, gojson.TComma},
+		{"null}", 4, gojson.TRCurlyBracket},
+		{"[] ]", 3, gojson.TRSquareBracket},
+	}
+
+	for _, tt := range garbage {
+		_, err := Setup(tt.json).Parse()
+		if assert.Error(t, err, tt.json) {
+			psErr := err.(*gojson.ParserError)
+			assert.Equal(t, gojson.SyntaxError, psErr.ErrorType, tt.json)
+			assert.Equal(t, tt.offset, psErr.Start.Offset, tt.json)
+			assert.Equal(t, []gojson.TokenType{gojson.TEof}, psErr.ExpectedType, tt.json)
+			assert.Equal(t, tt.found, psErr.FoundType, tt.json)
+		}
+	}
+}
+
+func TestParser_Separators(t *testing.T) {
+	values := []gojson.TokenType{
+		gojson.TString, gojson.TNumber, gojson.TTrue, gojson.TFalse, gojson.TNull, gojson.TLCurlyBracket, gojson.TLSquareBracket,
+	}
+	var tests = []struct {
+		json     string
+		offset   int
+		expected []gojson.TokenType
+		found    gojson.TokenType
+	}{
+		{"[1 2 3]", 3, []gojson.TokenType{gojson.TComma, gojson.TRSquareBracket}, gojson.TNumber},
+		{"[,,1,]", 1, values, gojson.TComma},
+		{"[1,,2]", 3, values, gojson.TComma},
+		{"[1,]", 2, []gojson.TokenType{gojson.TRSquareBracket}, gojson.TComma},
+		{"[[1],]", 4, []gojson.TokenType{gojson.TRSquareBracket}, gojson.TComma},
+		{"[,]", 1, values, gojson.TComma},
+		{"{\"a\":1 \"b\":2}", 7, []gojson.TokenType{gojson.TComma, gojson.TRCurlyBracket}, gojson.TString},
+		{"{,\"a\":1}", 1, []gojson.TokenType{gojson.TString}, gojson.TComma},
+		{"{\"a\":1,,\"b\":2}", 7, []gojson.TokenType{gojson.TString}, gojson.TComma},
+		{"{\"a\":1,}", 6, []gojson.TokenType{gojson.TRCurlyBracket}, gojson.TComma},
+		{"{\"a\":[1 }", 8, []gojson.TokenType{gojson.TComma, gojson.TRSquareBracket}, gojson.TRCurlyBracket},
+	}
+
+	for _, tt := range tests {
+		_, err := Setup(tt.json).Parse()
+		if assert.Error(t, err, tt.json) {
+			psErr := err.(*gojson.ParserError)
+			assert.Equal(t, gojson.SyntaxError, psErr.ErrorType, tt.json)
+			assert.Equal(t, tt.offset, psErr.Start.Offset, tt.json)
+			assert.Equal(t, tt.expected, psErr.ExpectedType, tt.json)
+			assert.Equal(t, tt.found, psErr.FoundType, tt.json)
+		}
+	}
+
+	// a single trailing comma only behind the option
+	for _, json := range []string{"[1,]", "{\"a\":1,}", "[[1,],{\"b\":[],},]"} {
+		ps := Setup(json)
+		ps.Options.AllowTrailingComma = true
+		_, err := ps.Parse()
+		assert.NoError(t, err, json)
+	}
+	for _, json := range []string{"[1,,]", "[,]", "{,}", "[1 2,]"} {
+		ps := Setup(json)
+		ps.Options.AllowTrailingComma = true
+		_, err := ps.Parse()
+		assert.Error(t, err, json)
+	}
+}
+
+func TestParser_Malformed(t *testing.T) {
+	var tests = []struct {
+		title  string
+		json   string
+		offset int
+		found  gojson.TokenType
+	}{
+		{"empty input", "", 0, gojson.TEof},
+		{"colon root", ": 1", 0, gojson.TColon},
+		{"closing root", "]", 0, gojson.TRSquareBracket},
+		{"object unclosed", "{", 1, gojson.TEof},
+		{"object key only", "{\"a\"", 4, gojson.TEof},
+		{"object no value", "{\"a\":}", 5, gojson.TRCurlyBracket},
+		{"object unclosed value", "{\"a\":", 5, gojson.TEof},
+		{"object no colon", "{\"a\" 1}", 5, gojson.TNumber},
+		{"object number key", "{1: 2}", 1, gojson.TNumber},
+		{"object colon value", "{\"a\"::1}", 5, gojson.TColon},
+		{"object wrong bracket", "{\"a\": 1]", 7, gojson.TRSquareBracket},
+		{"array unclosed", "[", 1, gojson.TEof},
+		{"array colon", "[:]", 1, gojson.TColon},
+		{"array wrong bracket", "[1}", 2, gojson.TRCurlyBracket},
+		{"array closing curly", "[}]", 1, gojson.TRCurlyBracket},
+		{"array pair", "[\"a\": 1]", 4, gojson.TColon},
+		{"nested object", "{\"a\": {\"b\": [1, :]}}", 16, gojson.TColon},
+		{"nested array", "[[[1], [2 3]]]", 10, gojson.TNumber},
+		{"nested pair", "[{\"a\": 1, \"b\"}]", 13, gojson.TRCurlyBracket},
+	}
+
+	for _, tt := range tests {
+		js, err := Setup(tt.json).Parse()
+		assert.Nil(t, js, tt.title)
+		if assert.Error(t, err, tt.title) {
+			psErr, ok := err.(*gojson.ParserError)
+			if assert.True(t, ok, tt.title) {
+				errorType := gojson.SyntaxError
+				if tt.found == gojson.TEof {
+					errorType = gojson.UnexpectedEndOfInputError
+				}
+				assert.Equal(t, errorType, psErr.ErrorType, tt.title)
+				assert.Equal(t, tt.offset, psErr.Start.Offset, tt.title)
+				assert.Equal(t, tt.found, psErr.FoundType, tt.title)
+			}
+		}
+	}
+
+	// every production fails without a node
+	obj, err := Setup("[]").ParseObject()
+	assert.Nil(t, obj)
+	assert.Error(t, err)
+	arr, err := Setup("{}").ParseArray()
+	assert.Nil(t, arr)
+	assert.Error(t, err)
+	pair, err := Setup("1").ParsePair()
+	assert.Nil(t, pair)
+	assert.Error(t, err)
+	val, err := Setup(",").ParseValue()
+	assert.Nil(t, val)
+	assert.Error(t, err)
+	member, err := Setup("\"a\" 1}").ParseMember()
+	assert.Nil(t, member)
+	assert.Error(t, err)
+	element, err := Setup("1 2]").ParseElement()
+	assert.Nil(t, element)
+	assert.Error(t, err)
+
+	// a key that can not be decoded is reported as a ParserError too
+	ps := gojson.NewParser(&[]gojson.Token{
+		*gojson.NewToken(gojson.TLCurlyBracket, "{", 0, 1),
+		*gojson.NewToken(gojson.TString, "\\q", 1, 5),
+		*gojson.NewToken(gojson.TColon, ":", 5, 6),
+		*gojson.NewToken(gojson.TNumber, "1", 6, 7),
+		*gojson.NewToken(gojson.TRCurlyBracket, "}", 7, 8),
+		*gojson.NewToken(gojson.TEof, "", 8, 8),
+	})
+	_, err = ps.Parse()
+	if assert.IsType(t, &gojson.ParserError{}, err) {
+		assert.Equal(t, gojson.InvalidEscapeError, err.(*gojson.ParserError).ErrorType)
+		assert.Equal(t, 2, err.(*gojson.ParserError).Start.Offset)
+	}
+}
+
+func TestParser_Truncated(t *testing.T) {
+	tokens := *gojson.NewTokenizer("{\"a\": [1, 2]}").MustTokenize()
+	// drop TEof and more and more of the value
+	for n := len(tokens) - 2; n >= 0; n-- {
+		truncated := tokens[:n]
+		_, err := gojson.NewParser(&truncated).Parse()
+		if assert.Error(t, err, n) {
+			psErr := err.(*gojson.ParserError)
+			assert.Equal(t, gojson.UnexpectedEndOfInputError, psErr.ErrorType, n)
+			assert.Equal(t, gojson.TEof, psErr.FoundType, n)
+			if n > 0 {
+				assert.Equal(t, tokens[n-1].End, psErr.Start.Offset, n)
+			} else {
+				assert.Equal(t, gojson.Position{Line: 1, Column: 1}, psErr.Start)
+			}
+		}
+	}
+
+	// a complete value is fine without its TEof
+	complete := tokens[:len(tokens)-1]
+	_, err := gojson.NewParser(&complete).Parse()
+	assert.NoError(t, err)
+
+	ps := gojson.NewParser(&[]gojson.Token{})
+	assert.Equal(t, gojson.TEof, ps.Token().Type)
+	assert.Equal(t, gojson.TEof, ps.NextToken().Type)
+	assert.Equal(t, gojson.TEof, ps.PrevToken().Type)
+}
+
+func TestParser_MaxDepth(t *testing.T) {
+	nested := func(depth int) string {
+		return strings.Repeat("[", depth) + strings.Repeat("]", depth)
+	}
+
+	_, err := Setup(nested(gojson.DefaultMaxDepth)).Parse()
+	assert.NoError(t, err)
+	_, err = Setup(nested(gojson.DefaultMaxDepth + 1)).Parse()
+	if assert.Error(t, err) {
+		psErr := err.(*gojson.ParserError)
+		assert.Equal(t, gojson.DepthLimitError, psErr.ErrorType)
+		assert.Equal(t, gojson.DefaultMaxDepth, psErr.Start.Offset)
+		assert.Equal(t, "$"+strings.Repeat("[0]", gojson.DefaultMaxDepth), psErr.Path)
+	}
+
+	var tests = []struct {
+		json   string
+		path   string
+		offset int
+	}{
+		{"{\"a\": [{\"b\": [1]}]}", "$.a[0].b", 13},
+		{"[1, {\"x y\": {\"z\": []}}]", "$[1][\"x y\"].z", 18},
+		{"{\"a\": 1, \"b\": [[], [[]]]}", "$.b[1][0]", 20},
+	}
+
+	for _, tt := range tests {
+		ps := Setup(tt.json)
+		ps.Options.MaxDepth = 3
+		_, err := ps.Parse()
+		if assert.Error(t, err, tt.json) {
+			psErr := err.(*gojson.ParserError)
+			assert.Equal(t, gojson.DepthLimitError, psErr.ErrorType, tt.json)
+			assert.Equal(t, tt.path, psErr.Path, tt.json)
+			assert.Equal(t, tt.offset, psErr.Start.Offset, tt.json)
+			assert.Equal(t, "nesting depth exceeds 3", psErr.ErrorMessage, tt.json)
+		}
+	}
+
+	ps := Setup("[[], {\"a\": [1]}, [[2]]]")
+	ps.Options.MaxDepth = 3
+	_, err = ps.Parse()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, ps.Depth)
+
+	// a negative MaxDepth lifts the limit
+	ps = Setup(nested(2 * gojson.DefaultMaxDepth))
+	ps.Options.MaxDepth = -1
+	_, err = ps.Parse()
+	assert.NoError(t, err)
+
+	// a huge document is refused without growing the stack
+	st := gojson.NewStreamTokenizer(strings.NewReader(strings.Repeat("[", 1<<20)))
+	_, err = gojson.NewStreamParser(st).Parse()
+	if assert.Error(t, err) {
+		assert.Equal(t, gojson.DepthLimitError, err.(*gojson.ParserError).ErrorType)
+		// the message stays short however deep the path is
+		assert.Equal(t, "[p-DepthLimitError @ 1:1001] nesting depth exceeds 1000", err.Error())
+	}
+}
+
+func TestParser_Limits(t *testing.T) {
+	var tests = []struct {
+		json    string
+		options gojson.ParserOptions
+		msg     string
+		path    string
+		offset  int
+	}{
+		{"{\"a\": 1, \"b\": 2, \"c\": 3}", gojson.ParserOptions{MaxObjectMembers: 2}, "object exceeds the limit of 2 members", "$", 17},
+		{"{\"a\": [{\"b\": 1, \"c\": 2}]}", gojson.ParserOptions{MaxObjectMembers: 1}, "object exceeds the limit of 1 members", "$.a[0]", 16},
+		{"[1, 2, 3]", gojson.ParserOptions{MaxArrayElements: 2}, "array exceeds the limit of 2 elements", "$", 7},
+		{"{\"a\": [[1, 2, 3]]}", gojson.ParserOptions{MaxArrayElements: 2}, "array exceeds the limit of 2 elements", "$.a[0]", 14},
+		{"[1, [2, 3]]", gojson.ParserOptions{MaxNodes: 4}, "document exceeds the limit of 4 nodes", "$[1][1]", 8},
+		{"{\"a\": {\"b\": 1}}", gojson.ParserOptions{MaxNodes: 3}, "document exceeds the limit of 3 nodes", "$.a", 7},
+	}
+
+	for _, tt := range tests {
+		ps := Setup(tt.json)
+		ps.Options = tt.options
+		_, err := ps.Parse()
+		if assert.Error(t, err, tt.json) {
+			psErr := err.(*gojson.ParserError)
+			assert.Equal(t, gojson.LimitError, psErr.ErrorType, tt.json)
+			assert.Equal(t, tt.msg, psErr.ErrorMessage, tt.json)
+			assert.Equal(t, tt.path, psErr.Path, tt.json)
+			assert.Equal(t, tt.offset, psErr.Start.Offset, tt.json)
+		}
+	}
+
+	// right at the limits
+	ps := Setup("{\"a\": [1, 2], \"b\": {}}")
+	ps.Options = gojson.ParserOptions{MaxObjectMembers: 2, MaxArrayElements: 2, MaxNodes: 7}
+	_, err := ps.Parse()
+	assert.NoError(t, err)
+}
+
+func TestParser_DuplicateKeys(t *testing.T) {
+	json := "{\"a\": 1, \"b\": 2, \"a\": 3, \"a\": 4}"
+
+	parse := func(policy gojson.DuplicateKeyPolicy) (*gojson.Json, error) {
+		ps := Setup(json)
+		ps.Options.DuplicateKeys = policy
+		return ps.Parse()
+	}
+	keys := func(js *gojson.Json) []string {
+		var keys []string
+		for _, pair := range *js.Node().Children {
+			keys = append(keys, pair.Key)
+		}
+		return keys
+	}
+
+	// keep all
+	js, err := parse(gojson.DuplicateKeysKeepAll)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"a", "b", "a", "a"}, keys(js))
+		var values []float64
+		for _, nd := range js.Node().GetAll("a") {
+			values = append(values, nd.Val.MustLoadAsFloat64())
+		}
+		assert.Equal(t, []float64{1, 3, 4}, values)
+		assert.Equal(t, float64(4), js.Node().Get("a").Val.MustLoadAsFloat64())
+		assert.Nil(t, js.Node().Get("c"))
+		mp, _ := js.Map()
+		assert.Equal(t, map[string]interface{}{"a": float64(4), "b": float64(2)}, mp)
+	}
+
+	// first wins
+	js, err = parse(gojson.DuplicateKeysFirstWins)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"a", "b"}, keys(js))
+		assert.Len(t, js.Node().GetAll("a"), 1)
+		mp, _ := js.Map()
+		assert.Equal(t, map[string]interface{}{"a": float64(1), "b": float64(2)}, mp)
+	}
+
+	// last wins, in the place of the first
+	js, err = parse(gojson.DuplicateKeysLastWins)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"a", "b"}, keys(js))
+		assert.Equal(t, float64(4), js.Node().Get("a").Val.MustLoadAsFloat64())
+		mp, _ := js.Map()
+		assert.Equal(t, map[string]interface{}{"a": float64(4), "b": float64(2)}, mp)
+	}
+
+	// error
+	_, err = parse(gojson.DuplicateKeysError)
+	if assert.Error(t, err) {
+		psErr := err.(*gojson.ParserError)
+		assert.Equal(t, gojson.DuplicateKeyError, psErr.ErrorType)
+		assert.Equal(t, "duplicate key \"a\", first defined at 1:2", psErr.ErrorMessage)
+		assert.Equal(t, linePos(17), psErr.Start)
+		assert.Equal(t, linePos(23), psErr.End)
+		assert.Equal(t, linePos(1), psErr.PrevStart)
+		assert.Equal(t, linePos(7), psErr.PrevEnd)
+		assert.Equal(t, "$", psErr.Path)
+	}
+
+	// keys are compared decoded, and per object
+	ps := Setup("{\"x\": {\"a\": 1}, \"y\": {\"a\": 2, \"\\u0061\": 3}}")
+	ps.Options.DuplicateKeys = gojson.DuplicateKeysError
+	_, err = ps.Parse()
+	if assert.Error(t, err) {
+		assert.Equal(t, "$.y", err.(*gojson.ParserError).Path)
+	}
+}
+
+func TestParser_ParseRecover(t *testing.T) {
+	var tests = []struct {
+		title    string
+		json     string
+		expected string
+		messages []string
+	}{
+		{
+			"valid",
+			`{"a": [1, 2]}`,
+			`{"a":[1,2]}`,
+			nil,
+		},
+		{
+			"missing value",
+			`[1, , 2]`,
+			`[1,2]`,
+			[]string{"1:5: error: expected a value, but found `,`"},
+		},
+		{
+			"missing comma and stray tokens",
+			`{"a": 1 "b": 2, "c" 3, : 4, "d": [1 : 2]}`,
+			`{"a":1,"b":2,"c":3,"d":[1,2]}`,
+			[]string{
+				"1:9: error: expected `,` or `}`, but found `b`",
+				"1:21: error: expected `:`, but found `3`",
+				"1:24: error: expected `TString`, but found `:`",
+				"1:37: error: expected `,` or `]`, but found `:`",
+			},
+		},
+		{
+			"unclosed brackets",
+			`{"a": [1, 2, "b": {"c": 3`,
+			`{"a":[1,2,"b",{"c":3}]}`,
+			[]string{
+				"1:1: error: `{` is never closed",
+				"1:7: error: `[` is never closed",
+				"1:17: error: expected `,` or `]`, but found `:`",
+				"1:19: error: `{` is never closed",
+			},
+		},
+		{
+			"mismatched bracket",
+			`{"a": [1, 2}`,
+			`{"a":[1,2]}`,
+			[]string{"1:7: error: `[` is never closed"},
+		},
+		{
+			"stray closing bracket",
+			`[1, 2]]`,
+			`[1,2]`,
+			[]string{"1:7: error: expected the end of input, but found `]`"},
+		},
+		{
+			"trailing comma",
+			`[1, 2,]`,
+			`[1,2]`,
+			[]string{"1:6: error: trailing comma is not allowed before `]`"},
+		},
+		{
+			"nothing",
+			``,
+			`null`,
+			[]string{"1:1: error: expected a value, but found ``"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			js, diagnostics := Setup(tt.json).ParseRecover()
+			var value interface{}
+			if js != nil {
+				value, _ = js.Value()
+			}
+			b, _ := json.Marshal(value)
+			assert.Equal(t, tt.expected, string(b))
+
+			var messages []string
+			for _, d := range diagnostics {
+				messages = append(messages, d.String())
+			}
+			assert.Equal(t, tt.messages, messages)
+		})
+	}
+}
+
+func TestParser_ParseRecover_Order(t *testing.T) {
+	// the unclosed array is reported at its bracket, before what follows inside it
+	_, diagnostics := Setup(`[[1 2`).ParseRecover()
+	var messages []string
+	for _, d := range diagnostics {
+		messages = append(messages, d.String())
+	}
+	assert.Equal(t, []string{
+		"1:1: error: `[` is never closed",
+		"1:2: error: `[` is never closed",
+		"1:5: error: expected `,` or `]`, but found `2`",
+	}, messages)
+}
+
+func TestParser_ParseRecover_Limits(t *testing.T) {
+	ps := Setup(`[[[1]], [2, ]`)
+	ps.Options.MaxDepth = 2
+	js, diagnostics := ps.ParseRecover()
+	if assert.Len(t, diagnostics, 1) {
+		assert.Equal(t, gojson.DepthLimitError, diagnostics[0].ErrorType)
+	}
+	assert.NotNil(t, js)
+
+	// duplicate keys are warnings, unless they are errors
+	ps = Setup(`{"a": 1, "a": 2}`)
+	js, diagnostics = ps.ParseRecover()
+	if assert.Len(t, diagnostics, 1) {
+		assert.Equal(t, gojson.SeverityWarning, diagnostics[0].Severity)
+		assert.Equal(t, gojson.DuplicateKeyError, diagnostics[0].ErrorType)
+	}
+	assert.Len(t, *js.Node().Children, 2)
+
+	ps = Setup(`{"a": 1, "a": 2}`)
+	ps.Options.DuplicateKeys = gojson.DuplicateKeysError
+	js, diagnostics = ps.ParseRecover()
+	if assert.Len(t, diagnostics, 1) {
+		assert.Equal(t, gojson.SeverityError, diagnostics[0].Severity)
+	}
+	mp, _ := js.Map()
+	assert.Equal(t, map[string]interface{}{"a": float64(1)}, mp)
+}
+
+func TestDiagnose(t *testing.T) {
+	tk := gojson.NewTokenizer("{\"a\": tru, \"b\": \"x\\q\", \"c\": [1, nul, 3]\n\"d\": 4}")
+	js, diagnostics := gojson.Diagnose(tk, gojson.ParserOptions{})
+
+	var messages []string
+	for _, d := range diagnostics {
+		messages = append(messages, d.String())
+	}
+	assert.Equal(t, []string{
+		"1:7: error: undefined keyword",
+		"1:19: error: invalid escape sequence",
+		"1:33: error: undefined keyword",
+		"2:1: error: expected `,` or `}`, but found `d`",
+	}, messages)
+
+	mp, err := js.Map()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"c": []interface{}{float64(1), float64(3)},
+		"d": float64(4),
+	}, mp)
+
+	tk = gojson.NewTokenizer(`{ab\x: 1, "c": 2}`)
+	tk.Options.Dialect = gojson.DialectJSON5
+	js, diagnostics = gojson.Diagnose(tk, gojson.ParserOptions{})
+	messages = nil
+	for _, d := range diagnostics {
+		messages = append(messages, d.String())
+	}
+	assert.Equal(t, []string{"1:4: error: invalid escape sequence in identifier"}, messages)
+	mp, err = js.Map()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"c": float64(2)}, mp)
+}
+
+func TestParser_Position(t *testing.T) {
+	json := "{\n  \"名前\": [1,\n    2],\n  \"名前\": 3\n}"
+	tk := gojson.NewTokenizer(json)
+	js, err := gojson.NewParser(tk.MustTokenize()).Parse()
+	if assert.NoError(t, err) {
+		values := js.Node().GetAll("名前")
+		assert.Equal(t, gojson.Position{Offset: 14, Rune: 10, Line: 2, Column: 9}, tk.Position(values[0].Start))
+		assert.Equal(t, gojson.Position{Offset: 24, Rune: 20, Line: 3, Column: 7}, tk.Position(values[0].End))
+	}
+
+	// the positions in the errors of a stream are worked out the same,
+	// also for what has left its buffer by the time the error is found
+	st := gojson.NewStreamTokenizer(iotest.OneByteReader(strings.NewReader(json)))
+	ps := gojson.NewStreamParser(st)
+	ps.Options.DuplicateKeys = gojson.DuplicateKeysError
+	_, err = ps.Parse()
+	var psErr *gojson.ParserError
+	if assert.ErrorAs(t, err, &psErr) {
+		assert.Equal(t, gojson.Position{Offset: 28, Rune: 24, Line: 4, Column: 3}, psErr.Start)
+		assert.Equal(t, gojson.Position{Offset: 4, Rune: 4, Line: 2, Column: 3}, psErr.PrevStart)
+		assert.Equal(t, gojson.Position{Offset: 24, Rune: 20, Line: 3, Column: 7}, psErr.PrevEnd)
+		assert.Equal(t, "duplicate key \"名前\", first defined at 2:3", psErr.ErrorMessage)
+	}
+}
+
+func TestParserError_Error(t *testing.T) {
+	ps := Setup("[1, 2,\n  3}")
+	ps.SourceName = "data.json"
+	_, err := ps.Parse()
+	assert.EqualError(t, err, "data.json:2:4: [p-SyntaxError] expected `,` or `]`, but found `}`")
+}
+
+func TestParserError_Is(t *testing.T) {
+	var tests = []struct {
+		json     string
+		sentinel error
+	}{
+		{"[1, 2", gojson.ErrUnexpectedEOF},
+		{"[1, 2}", gojson.ErrSyntax},
+		{"[[[1]]]", gojson.ErrDepthExceeded},
+		{"{\"a\": 1, \"a\": 2}", gojson.ErrDuplicateKey},
+	}
+
+	for _, tt := range tests {
+		ps := Setup(tt.json)
+		ps.Options.MaxDepth = 2
+		ps.Options.DuplicateKeys = gojson.DuplicateKeysError
+		_, err := ps.Parse()
+		assert.True(t, errors.Is(err, tt.sentinel), tt.json)
+		assert.False(t, errors.Is(err, gojson.ErrInvalidEscape), tt.json)
+	}
+
+	// tokenizer errors
+	_, err := gojson.NewTokenizer("[\"\\q\"]").Tokenize()
+	assert.True(t, errors.Is(err, gojson.ErrInvalidEscape))
+	_, err = gojson.NewTokenizer("[\"abc").Tokenize()
+	assert.True(t, errors.Is(err, gojson.ErrUnexpectedEOF))
+
+	// a malformed key wraps the tokenizer error
+	var tokens []gojson.Token
+	for _, tk := range []*gojson.Token{
+		gojson.NewToken(gojson.TLCurlyBracket, "{", 0, 1),
+		gojson.NewToken(gojson.TString, "\\q", 1, 5),
+		gojson.NewToken(gojson.TColon, ":", 5, 6),
+		gojson.NewToken(gojson.TNumber, "1", 7, 8),
+		gojson.NewToken(gojson.TRCurlyBracket, "}", 8, 9),
+		gojson.NewToken(gojson.TEof, "", 9, 9),
+	} {
+		tokens = append(tokens, *tk)
+	}
+	_, err = gojson.NewParser(&tokens).Parse()
+	var tkErr *gojson.TokenizerError
+	if assert.True(t, errors.As(err, &tkErr)) {
+		assert.Equal(t, gojson.InvalidEscapeError, tkErr.ErrorType)
+	}
+	assert.True(t, errors.Is(err, gojson.ErrInvalidEscape))
+}
+
+func TestParser_Comments(t *testing.T) {
+	json := "// config\n" +
+		"{\n" +
+		"  // the name\n" +
+		"  \"name\": \"gojson\", // inline\n" +
+		"  \"tags\": [\n" +
+		"    1, /* one */\n" +
+		"    2\n" +
+		"    // after two\n" +
+		"  ],\n" +
+		"  \"empty\": { /* nothing */ }\n" +
+		"} // end\n"
+
+	comments := func(tokens []gojson.Token) []string {
+		var texts []string
+		for _, token := range tokens {
+			texts = append(texts, string(token.Raw()))
+		}
+		return texts
+	}
+
+	tk := gojson.NewTokenizer(json)
+	tk.Options.Comments = true
+	ps := gojson.NewParser(tk.MustTokenize())
+	ps.Options.KeepComments = true
+	obj, err := ps.ParseObject()
+	if !assert.NoError(t, err) {
+		return
+	}
+	members := *obj.Children
+	assert.Equal(t, []string{"// config"}, comments(obj.LeadingComments))
+	assert.Equal(t, []string{"// the name"}, comments(members[0].LeadingComments))
+	assert.Equal(t, []string{"// inline"}, comments(members[0].TrailingComments))
+
+	elements := *(*members[1].Children)[0].Children
+	assert.Equal(t, []string{"/* one */"}, comments(elements[0].TrailingComments))
+	assert.Equal(t, []string{"// after two"}, comments(elements[1].TrailingComments))
+
+	empty := (*members[2].Children)[0]
+	assert.Equal(t, []string{"/* nothing */"}, comments(empty.TrailingComments))
+
+	// comments are dropped by default
+	tk = gojson.NewTokenizer(json)
+	tk.Options.Comments = true
+	obj, err = gojson.NewParser(tk.MustTokenize()).ParseObject()
+	if assert.NoError(t, err) {
+		assert.Nil(t, obj.LeadingComments)
+		assert.Nil(t, (*obj.Children)[0].TrailingComments)
+	}
+}
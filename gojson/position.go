@@ -0,0 +1,176 @@
+package gojson
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+)
+
+// Position locates a point in the source.
+type Position struct {
+	// Offset is the byte offset, starting at 0
+	Offset int
+	// Rune is the offset in runes, starting at 0
+	Rune int
+	// Line starts at 1
+	Line int
+	// Column is counted in runes, starting at 1
+	Column int
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// advance moves p over the UTF-8 text in b.
+func advance(p Position, b []byte) Position {
+	for _, c := range b {
+		p.Offset++
+		// continuation bytes belong to the rune they follow
+		if c&0xC0 == 0x80 {
+			continue
+		}
+		p.Rune++
+		if c == '\n' {
+			p.Line++
+			p.Column = 1
+		} else {
+			p.Column++
+		}
+	}
+	return p
+}
+
+// mark is a point in the input, as byte and rune offset.
+type mark struct {
+	byte int
+	rune int
+}
+
+// advance moves m over the UTF-8 text in b.
+func (m mark) advance(b []byte) mark {
+	return mark{byte: m.byte + len(b), rune: m.rune + countRunes(b)}
+}
+
+// countRunes counts the runes of the UTF-8 text in b,
+// continuation bytes belong to the rune they follow.
+func countRunes(b []byte) int {
+	n := 0
+	for _, c := range b {
+		if c&0xC0 != 0x80 {
+			n++
+		}
+	}
+	return n
+}
+
+// lineTable records where the lines of a piece of the input start, as far as it has been scanned.
+type lineTable struct {
+	// start is the Position of the first byte of the piece
+	start Position
+	// starts are the lines starting behind start
+	starts []mark
+	// scanned is where the scan for line starts stopped
+	scanned mark
+	// last is where position last counted runes up to
+	last mark
+}
+
+func newLineTable(start Position) lineTable {
+	at := mark{byte: start.Offset, rune: start.Rune}
+	return lineTable{start: start, scanned: at, last: at}
+}
+
+// scan records the lines starting up to the byte offset to. data is the piece of the input.
+func (l *lineTable) scan(data []byte, to int) {
+	base := l.start.Offset
+	if end := base + len(data); to > end {
+		to = end
+	}
+	for l.scanned.byte < to {
+		rest := data[l.scanned.byte-base : to-base]
+		i := bytes.IndexByte(rest, '\n')
+		if i < 0 {
+			l.scanned = l.scanned.advance(rest)
+			return
+		}
+		l.scanned = l.scanned.advance(rest[:i+1])
+		l.starts = append(l.starts, l.scanned)
+	}
+}
+
+// line returns the index into starts of the line o is on, -1 for the first line.
+func (l *lineTable) line(data []byte, o int) int {
+	l.scan(data, o)
+	return sort.Search(len(l.starts), func(i int) bool { return l.starts[i].byte > o }) - 1
+}
+
+// position returns the Position of the byte offset o in data.
+func (l *lineTable) position(data []byte, o int) Position {
+	n := l.line(data, o)
+	from, column := mark{byte: l.start.Offset, rune: l.start.Rune}, l.start.Column
+	if n >= 0 {
+		from, column = l.starts[n], 1
+	}
+
+	// the runes are counted on from the nearest point known on the line
+	at := from
+	switch {
+	case l.scanned.byte <= o && from.byte <= l.scanned.byte:
+		at = l.scanned
+	case l.last.byte <= o && from.byte <= l.last.byte:
+		at = l.last
+	}
+	if end := l.start.Offset + len(data); o <= end {
+		at = at.advance(data[at.byte-l.start.Offset : o-l.start.Offset])
+	} else {
+		// beyond the piece, the bytes are taken for runes
+		at = at.advance(data[at.byte-l.start.Offset:])
+		at = mark{byte: o, rune: at.rune + o - end}
+	}
+	l.last = at
+	return Position{Offset: o, Rune: at.rune, Line: l.start.Line + n + 1, Column: column + at.rune - from.rune}
+}
+
+// source is the input the offsets of tokens refer to.
+type source struct {
+	// data is the piece of the input from lines.start.Offset on
+	data  []byte
+	lines lineTable
+	// surrogates and dialect decide how the escapes of a TString are decoded
+	surrogates LoneSurrogatePolicy
+	dialect    Dialect
+	// made is set for the source of a token made by NewToken, data is then its Raw
+	made bool
+}
+
+func newSource(data []byte, start Position, options TokenizerOptions) *source {
+	return &source{
+		data:       data,
+		lines:      newLineTable(start),
+		surrogates: options.LoneSurrogates,
+		dialect:    options.Dialect,
+	}
+}
+
+// covers reports whether the byte offset o lies in s, or right behind it.
+func (s *source) covers(o int) bool {
+	return s != nil && !s.made && s.lines.start.Offset <= o && o <= s.lines.start.Offset+len(s.data)
+}
+
+// position works out the Position of the byte offset o. Without data to go by,
+// for a token made by hand, the source is taken to be a single line of ASCII.
+func (s *source) position(o int) Position {
+	if !s.covers(o) {
+		return Position{Offset: o, Rune: o, Line: 1, Column: o + 1}
+	}
+	return s.lines.position(s.data, o)
+}
+
+// line returns the line number of the byte offset o, as position does.
+func (s *source) line(o int) int {
+	if !s.covers(o) {
+		return 1
+	}
+	return s.lines.start.Line + s.lines.line(s.data, o) + 1
+}
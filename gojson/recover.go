@@ -0,0 +1,396 @@
+package gojson
+
+import (
+	"fmt"
+	"sort"
+)
+
+type Severity int
+
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityWarning:
+		return "warning"
+	default:
+		return "error"
+	}
+}
+
+// Diagnostic is a problem found by Parser.ParseRecover or Diagnose.
+type Diagnostic struct {
+	Severity  Severity
+	ErrorType ErrorType
+	Message   string
+
+	// Start <= problem < End
+	Start Position
+	End   Position
+
+	// Expected are the tokens that would have been fine and Found the one there was,
+	// if the problem is an unexpected token
+	Expected []TokenType
+	Found    TokenType
+
+	// err is the error reported, for Decoder
+	err *ParserError
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%v: %v: %v", d.Start, d.Severity, d.Message)
+}
+
+// Diagnose tokenizes and parses the input of tk, carrying on past every error.
+// It returns the tree of what could be parsed, as ParseRecover does, and the
+// problems of both the tokenizer and the parser in input order.
+func Diagnose(tk *Tokenizer, options ParserOptions) (*Json, []Diagnostic) {
+	tokens, errs := tk.TokenizeAll()
+	p := NewParser(tokens)
+	p.Options = options
+	p.SourceName = tk.SourceName
+	js, parsed := p.ParseRecover()
+
+	var diagnostics []Diagnostic
+	for _, err := range errs {
+		diagnostics = append(diagnostics, Diagnostic{
+			Severity:  SeverityError,
+			ErrorType: err.ErrorType,
+			Message:   err.ErrorMessage,
+			Start:     err.Start,
+			End:       err.End,
+		})
+	}
+	for _, d := range parsed {
+		// a malformed token has been reported by the tokenizer already
+		if d.Found != TUnknown {
+			diagnostics = append(diagnostics, d)
+		}
+	}
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		return diagnostics[i].Start.Offset < diagnostics[j].Start.Offset
+	})
+	return js, diagnostics
+}
+
+// ParseRecover parses like Parse, but instead of stopping at the first error it
+// reports it, skips ahead to a `,`, `}` or `]` and carries on. It returns the tree
+// of what could be parsed, leaving out the values that could not, or nil if there
+// is nothing, and the problems in input order.
+// Exceeding MaxDepth or a limit still ends the parse.
+func (p *Parser) ParseRecover() (*Json, []Diagnostic) {
+	p.diagnostics = nil
+	p.aborted = false
+
+	nd := p.recoverValue()
+	if tk := p.Token(); !p.aborted && tk.Type != TEof {
+		p.report(SeverityError, &ParserError{
+			ErrorType:    SyntaxError,
+			ErrorMessage: fmt.Sprintf("expected the end of input, but found `%v`", string(tk.Raw())),
+			Start:        p.position(tk.Start),
+			End:          p.position(tk.End),
+			ExpectedType: []TokenType{TEof},
+			FoundType:    tk.Type,
+		})
+	}
+	if tkErr, ok := p.sourceErr.(*TokenizerError); ok {
+		p.diagnostics = append(p.diagnostics, Diagnostic{
+			Severity:  SeverityError,
+			ErrorType: tkErr.ErrorType,
+			Message:   tkErr.ErrorMessage,
+			Start:     tkErr.Start,
+			End:       tkErr.End,
+		})
+	}
+
+	diagnostics := p.diagnostics
+	p.diagnostics = nil
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		return diagnostics[i].Start.Offset < diagnostics[j].Start.Offset
+	})
+	if nd == nil {
+		return nil, diagnostics
+	}
+	nd.TrailingComments = append(nd.TrailingComments, p.takeComments()...)
+	return NewJson(nd, nd.Type), diagnostics
+}
+
+// report records err as a diagnostic. Exceeding a limit ends the parse.
+func (p *Parser) report(severity Severity, err *ParserError) {
+	p.diagnostics = append(p.diagnostics, Diagnostic{
+		Severity:  severity,
+		ErrorType: err.ErrorType,
+		Message:   err.ErrorMessage,
+		Start:     err.Start,
+		End:       err.End,
+		Expected:  err.ExpectedType,
+		Found:     err.FoundType,
+		err:       err,
+	})
+	if err.ErrorType == DepthLimitError || err.ErrorType == LimitError {
+		p.aborted = true
+		p.abortedAt = len(p.closers)
+	}
+}
+
+// isSync reports whether typ is a token ParseRecover carries on from.
+func isSync(typ TokenType) bool {
+	return typ == TComma || typ == TRCurlyBracket || typ == TRSquareBracket || typ == TEof
+}
+
+func startsValue(typ TokenType) bool {
+	switch typ {
+	case TString, TNumber, TTrue, TFalse, TNull, TLCurlyBracket, TLSquareBracket:
+		return true
+	default:
+		return false
+	}
+}
+
+// closesOuter reports whether typ closes an object or array around the innermost one.
+func (p *Parser) closesOuter(typ TokenType) bool {
+	for i := 0; i < len(p.closers)-1; i++ {
+		if p.closers[i] == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// recoverValue parses a value, or reports why there is none and returns nil.
+// A stray token in its place is consumed, a `,`, `}`, `]` or TEof is not.
+func (p *Parser) recoverValue() *Node {
+	switch token := p.Token(); token.Type {
+	case TLCurlyBracket:
+		return p.recoverContainer(TRCurlyBracket)
+	case TLSquareBracket:
+		return p.recoverContainer(TRSquareBracket)
+	default:
+		nd, err := p.ParseValue()
+		if err != nil {
+			p.report(SeverityError, err.(*ParserError))
+			if !isSync(token.Type) {
+				p.GoNext()
+			}
+			return nil
+		}
+		return nd
+	}
+}
+
+// recoverContainer parses an object or array, the one closed by closing.
+func (p *Parser) recoverContainer(closing TokenType) *Node {
+	open := p.Token()
+	leading := p.takeComments()
+	if err := p.enter(open); err != nil {
+		p.report(SeverityError, err.(*ParserError))
+		return nil
+	}
+	defer func() { p.Depth-- }()
+	if err := p.addNode(open); err != nil {
+		p.report(SeverityError, err.(*ParserError))
+		return nil
+	}
+	// consume '{' or '['
+	p.GoNext()
+	p.closers = append(p.closers, closing)
+	defer func() { p.closers = p.closers[:len(p.closers)-1] }()
+
+	var children []Node
+	// seen locates the members by key
+	seen := map[string]keySeen{}
+	end := open.End
+
+Loop:
+	for !p.aborted {
+		token := p.Token()
+		switch {
+		case token.Type == closing:
+			end = token.End
+			// consume '}' or ']'
+			p.GoNext()
+			break Loop
+		case token.Type == TEof || p.closesOuter(token.Type):
+			p.report(SeverityError, &ParserError{
+				ErrorType:    endOrSyntaxError(token.Type),
+				ErrorMessage: fmt.Sprintf("`%v` is never closed", string(open.Raw())),
+				Start:        open.position(open.Start),
+				End:          open.position(open.End),
+				ExpectedType: []TokenType{closing},
+				FoundType:    token.Type,
+			})
+			end = p.PrevToken().End
+			break Loop
+		case token.Type == TRCurlyBracket || token.Type == TRSquareBracket:
+			p.report(SeverityError, &ParserError{
+				ErrorType:    SyntaxError,
+				ErrorMessage: fmt.Sprintf("expected `%v`, but found `%v`", closingBracket(closing), string(token.Raw())),
+				Start:        p.position(token.Start),
+				End:          p.position(token.End),
+				ExpectedType: []TokenType{closing},
+				FoundType:    token.Type,
+			})
+			p.GoNext()
+			continue
+		}
+
+		var nd *Node
+		first := p.Token()
+		if closing == TRCurlyBracket {
+			nd = p.recoverPair()
+		} else {
+			p.path = append(p.path, pathSegment{index: len(children)})
+			nd = p.recoverValue()
+			p.path = p.path[:len(p.path)-1]
+		}
+		if p.aborted {
+			break
+		}
+		last := p.PrevToken()
+		p.recoverSeparator(closing, nd)
+
+		if nd == nil {
+			continue
+		}
+		if prev, ok := seen[nd.Key]; ok && closing == TRCurlyBracket {
+			p.recoverDuplicate(nd.Key, first, last, prev)
+			if p.Options.DuplicateKeys != DuplicateKeysKeepAll {
+				if p.Options.DuplicateKeys == DuplicateKeysLastWins {
+					children[prev.index] = *nd
+					seen[nd.Key] = keySeen{index: prev.index, first: first, last: last}
+				}
+				continue
+			}
+		} else if closing == TRCurlyBracket {
+			seen[nd.Key] = keySeen{index: len(children), first: first, last: last}
+		}
+		children = append(children, *nd)
+	}
+
+	typ := NDArray
+	if closing == TRCurlyBracket {
+		typ = NDObject
+	}
+	nd := NewNode(typ, &children, "", nil)
+	nd.Start, nd.End = open.Start, end
+	nd.LeadingComments = leading
+	p.attachDangling(nd)
+	return nd
+}
+
+// recoverPair parses a member of an object, or reports why there is none and returns nil.
+func (p *Parser) recoverPair() *Node {
+	tkKey := p.Token()
+	leading := p.takeComments()
+	if tkKey.Type != TString && !p.isIdentifierKey(tkKey) {
+		_, err := p.ParsePair()
+		p.report(SeverityError, err.(*ParserError))
+		if isSync(tkKey.Type) {
+			return nil
+		}
+		// skip the rest of the member
+		p.GoNext()
+		if p.Token().Type == TColon {
+			p.GoNext()
+		}
+		if startsValue(p.Token().Type) {
+			p.recoverValue()
+		}
+		return nil
+	}
+	// consume key
+	p.GoNext()
+
+	if tkColon := p.Token(); tkColon.Type != TColon {
+		p.report(SeverityError, &ParserError{
+			ErrorType:    endOrSyntaxError(tkColon.Type),
+			ErrorMessage: fmt.Sprintf("expected `:`, but found `%v`", string(tkColon.Raw())),
+			Start:        p.position(tkColon.Start),
+			End:          p.position(tkColon.End),
+			ExpectedType: []TokenType{TColon},
+			FoundType:    tkColon.Type,
+		})
+		if !startsValue(tkColon.Type) {
+			return nil
+		}
+	} else {
+		// consume ":"
+		p.GoNext()
+	}
+
+	if err := p.addNode(tkKey); err != nil {
+		p.report(SeverityError, err.(*ParserError))
+		return nil
+	}
+	key, err := p.loadString(keyToken(tkKey))
+	if err != nil {
+		p.report(SeverityError, err.(*ParserError))
+		key = string(tkKey.Raw())
+	}
+
+	p.path = append(p.path, pathSegment{key: key, index: -1})
+	val := p.recoverValue()
+	p.path = p.path[:len(p.path)-1]
+	if val == nil {
+		return nil
+	}
+
+	pair := NewNode(NDPair, &[]Node{*val}, key, nil)
+	pair.Start, pair.End = tkKey.Start, val.End
+	pair.LeadingComments = leading
+	return pair
+}
+
+// recoverSeparator consumes the comma behind the item nd, if there is one.
+// Otherwise it skips the stray tokens up to a `,`, `}`, `]` or the start of a value.
+// Only a problem behind a well-formed item is reported, the item reported its own.
+func (p *Parser) recoverSeparator(closing TokenType, nd *Node) {
+	token := p.Token()
+	switch {
+	case token.Type == TComma:
+		// consume ','
+		p.GoNext()
+		if nd != nil {
+			p.attachTrailing(nd)
+		}
+		if next := p.Token(); next.Type == closing && nd != nil && !p.Options.AllowTrailingComma && p.Options.Dialect != DialectJSON5 {
+			p.report(SeverityError, &ParserError{
+				ErrorType:    SyntaxError,
+				ErrorMessage: fmt.Sprintf("trailing comma is not allowed before `%v`", closingBracket(closing)),
+				Start:        p.position(token.Start),
+				End:          p.position(token.End),
+				ExpectedType: []TokenType{closing},
+				FoundType:    TComma,
+			})
+		}
+	case isSync(token.Type):
+		// the container deals with it
+	default:
+		if nd != nil {
+			p.report(SeverityError, &ParserError{
+				ErrorType:    SyntaxError,
+				ErrorMessage: fmt.Sprintf("expected `,` or `%v`, but found `%v`", closingBracket(closing), string(token.Raw())),
+				Start:        p.position(token.Start),
+				End:          p.position(token.End),
+				ExpectedType: []TokenType{TComma, closing},
+				FoundType:    token.Type,
+			})
+		}
+		for tk := p.Token(); !isSync(tk.Type) && !startsValue(tk.Type); tk = p.Token() {
+			p.GoNext()
+		}
+	}
+}
+
+// recoverDuplicate reports the pair from first to last repeating the key defined at prev,
+// as an error with DuplicateKeysError and as a warning otherwise.
+func (p *Parser) recoverDuplicate(key string, first Token, last Token, prev keySeen) {
+	severity := SeverityWarning
+	if p.Options.DuplicateKeys == DuplicateKeysError {
+		severity = SeverityError
+	}
+	p.report(severity, p.duplicateKeyError(key, first, last, prev))
+}
@@ -0,0 +1,253 @@
+package gojson
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+const (
+	DefaultStreamBufferSize = 4096
+	DefaultMaxTokenSize     = 1 << 20
+)
+
+// TokenSource is anything that hands out tokens one at a time, ending with TEof.
+type TokenSource interface {
+	Next() (Token, error)
+}
+
+func NewStreamTokenizer(r io.Reader) *StreamTokenizer {
+	return &StreamTokenizer{
+		Reader:       r,
+		MaxTokenSize: DefaultMaxTokenSize,
+		tk: Tokenizer{
+			Source: make([]byte, 0, DefaultStreamBufferSize),
+		},
+	}
+}
+
+// StreamTokenizer tokenizes the data read from Reader through a bounded buffer.
+// Offsets of its tokens count from the start of the stream.
+// Like NewTokenizerBytes, it detects the encoding of the stream from its first
+// bytes and reads UTF-16 and UTF-32 as UTF-8, to which positions then refer.
+type StreamTokenizer struct {
+	Reader io.Reader
+	// MaxTokenSize is the largest token, in bytes, the buffer grows to hold
+	MaxTokenSize int
+	// SourceName is the file name or the like reported in errors
+	SourceName string
+	// Encoding is the encoding detected once the first token is read
+	Encoding Encoding
+
+	Options TokenizerOptions
+
+	// tk tokenizes the buffered window of the stream
+	tk Tokenizer
+	// r reads UTF-8 from Reader, it is set up by detect
+	r   io.Reader
+	eof bool
+	err error
+}
+
+// Next returns the next token of the stream, or TEof once the reader reports io.EOF.
+// Unlike the tokens of a Tokenizer, each token holds a copy of its part of the
+// stream, so that Raw stays valid and the token knows its own position.
+func (s *StreamTokenizer) Next() (Token, error) {
+	s.tk.Options = s.Options
+	s.tk.SourceName = s.SourceName
+	for {
+		startPos := s.tk.Pos
+		token, err := s.tk.Next()
+		if s.eof || isComplete(token, s.tk.base+len(s.tk.Source)) {
+			return s.emit(token, err)
+		}
+
+		// the token may go on in the data that has not been read yet,
+		// unless it already exceeds its limit
+		if limitErr := s.partialLimit(token); limitErr != nil {
+			token, err := s.emit(token, limitErr)
+			s.skipRest(token)
+			return token, err
+		}
+		s.tk.Pos = startPos
+		if s.err != nil {
+			return Token{}, s.err
+		}
+		if err := s.fill(); err != nil {
+			return Token{}, err
+		}
+	}
+}
+
+// isComplete reports whether token can not be continued by data from offset end on.
+func isComplete(token Token, end int) bool {
+	switch token.Type {
+	case TComma, TColon, TLCurlyBracket, TRCurlyBracket, TLSquareBracket, TRSquareBracket:
+		return true
+	default:
+		return token.End < end
+	}
+}
+
+// partialLimit returns the LimitError for the unfinished string or number token,
+// if the part of it in the buffer already exceeds TokenizerOptions.
+func (s *StreamTokenizer) partialLimit(token Token) *TokenizerError {
+	var max int
+	var kind string
+	size := token.End - token.Start
+	switch token.Type {
+	case TString:
+		// the opening quote does not count
+		max, kind, size = s.Options.MaxStringLength, "string", size-1
+	case TNumber:
+		max, kind = s.Options.MaxNumberLength, "number"
+	}
+	if max <= 0 || size <= max {
+		return nil
+	}
+	return &TokenizerError{
+		ErrorType:    LimitError,
+		ErrorMessage: fmt.Sprintf("%s exceeds the limit of %v bytes", kind, max),
+		Letters:      head(s.buffered(token.Start)),
+		Start:        s.tk.Position(token.Start),
+		End:          s.tk.Position(token.End),
+		SourceName:   s.SourceName,
+	}
+}
+
+// skipRest moves past the rest of the unfinished token, which partialLimit stopped.
+func (s *StreamTokenizer) skipRest(token Token) {
+	if token.Type == TString {
+		// the escapes of the part in the buffer are skipped again, one may be cut off at its end
+		s.tk.Pos = token.Start - s.tk.base + 1
+		s.skipString(token)
+		return
+	}
+	for {
+		for !s.tk.IsEof() && isNumberTail(s.tk.Source[s.tk.Pos]) {
+			s.tk.Pos++
+		}
+		if !s.tk.IsEof() || s.eof || s.err != nil || s.fill() != nil {
+			return
+		}
+	}
+}
+
+// isNumberTail reports whether c may go on a number, JSON5 hexadecimals included.
+func isNumberTail(c byte) bool {
+	return isNumberLetter(c) || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+// fill discards the consumed part of the buffer and reads more data into it.
+func (s *StreamTokenizer) fill() error {
+	buf := s.tk.Source
+	consumed := s.tk.Pos
+	if consumed > 0 {
+		// of the data behind, only the position it ends at is kept
+		start := s.tk.position(consumed)
+		n := copy(buf, buf[consumed:])
+		buf = buf[:n]
+		s.tk.base += consumed
+		s.tk.Pos = 0
+		s.tk.src = newSource(buf, start, s.Options)
+	}
+
+	// an unfinished token runs up to the end of the buffer
+	if len(buf) >= s.MaxTokenSize {
+		start := s.tk.position(0)
+		return &TokenizerError{
+			ErrorType:    TokenTooLongError,
+			ErrorMessage: "token exceeds the maximum token size",
+			Letters:      append([]byte{}, head(buf)...),
+			Start:        start,
+			End:          s.tk.position(len(buf)),
+			SourceName:   s.SourceName,
+		}
+	}
+	if len(buf) == cap(buf) {
+		size := 2 * cap(buf)
+		if size > s.MaxTokenSize {
+			size = s.MaxTokenSize
+		}
+		grown := make([]byte, len(buf), size)
+		copy(grown, buf)
+		buf = grown
+	}
+
+	if s.r == nil {
+		s.detect()
+	}
+	n, err := s.r.Read(buf[len(buf):cap(buf)])
+	s.tk.Source = buf[:len(buf)+n]
+	s.tk.src = newSource(s.tk.Source, s.tk.source().lines.start, s.Options)
+	if err == io.EOF {
+		s.eof = true
+	} else if err != nil {
+		s.err = err
+	}
+	return nil
+}
+
+// detect reads the first bytes of Reader to find out its encoding and sets up r.
+func (s *StreamTokenizer) detect() {
+	var head [4]byte
+	n, err := io.ReadFull(s.Reader, head[:])
+	enc, bomSize := DetectEncoding(head[:n])
+	s.Encoding, s.tk.Encoding = enc, enc
+
+	rest := s.Reader
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		rest = bytes.NewReader(nil)
+	} else if err != nil {
+		rest = errorReader{err}
+	}
+	s.r = io.MultiReader(bytes.NewReader(head[bomSize:n]), rest)
+	if s.Encoding != EncodingUTF8 {
+		s.r = &transcoder{r: s.r, enc: s.Encoding}
+	}
+}
+
+// skipString moves past the rest of the string token, which stopped at an invalid escape.
+func (s *StreamTokenizer) skipString(token Token) {
+	quote := s.tk.Source[token.Start-s.tk.base]
+	for !s.tk.skipString(quote) && !s.eof && s.err == nil {
+		if err := s.fill(); err != nil {
+			return
+		}
+	}
+}
+
+// Position works out the line and column of the byte offset o of a token or node
+// read from the stream, while o is in the buffer. Of the data that has left it, only
+// the position where the buffer starts is kept, so that memory stays bounded. Behind
+// the buffer, only the Offset of the Position is set. The positions in errors are
+// worked out while the tokens they refer to are at hand, and are always exact.
+func (s *StreamTokenizer) Position(o int) Position {
+	if o < s.tk.base {
+		return Position{Offset: o}
+	}
+	return s.tk.Position(o)
+}
+
+// buffered returns the data in the buffer from offset in the stream on.
+func (s *StreamTokenizer) buffered(offset int) []byte {
+	from := offset - s.tk.base
+	if from < 0 {
+		from = 0
+	}
+	if from > len(s.tk.Source) {
+		from = len(s.tk.Source)
+	}
+	return s.tk.Source[from:]
+}
+
+// emit detaches token and err from the buffer. The token gets a source of its own,
+// which starts at its position.
+func (s *StreamTokenizer) emit(token Token, err error) (Token, error) {
+	data := append([]byte{}, s.tk.Source[token.Start-s.tk.base:token.End-s.tk.base]...)
+	token.src = newSource(data, s.tk.Position(token.Start), s.Options)
+	if tkErr, ok := err.(*TokenizerError); ok {
+		tkErr.Letters = append([]byte{}, tkErr.Letters...)
+	}
+	return token, err
+}
@@ -0,0 +1,218 @@
+package gojson
+
+import (
+	"bytes"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestStreamTokenizer_Next(t *testing.T) {
+	json := "{\"msg\": \"hello \\\"world\\\"\",\n \"num\": [-12.5e3, 0, 1234567],\r\n \"ok\": true, \"名前\": null}\n  "
+	tk := NewTokenizer(json)
+	want := tk.MustTokenize()
+
+	st := NewStreamTokenizer(iotest.OneByteReader(strings.NewReader(json)))
+	var tokens []Token
+	for {
+		token, err := st.Next()
+		if !assert.NoError(t, err) {
+			return
+		}
+		// the token is still in the buffer when it is handed out
+		assert.Equal(t, tk.Position(token.Start), st.Position(token.Start), string(token.Raw()))
+		assert.Equal(t, tk.Position(token.End), st.Position(token.End), string(token.Raw()))
+		tokens = append(tokens, token)
+		if token.Type == TEof {
+			break
+		}
+	}
+	assert.Equal(t, views(*want), views(tokens))
+	// the lines of the data that has left the buffer are not kept
+	assert.Equal(t, Position{Offset: 1}, st.Position(1))
+
+	// keeps reporting the end of the stream
+	token, err := st.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, TEof, token.Type)
+}
+
+func TestStreamTokenizer_Next_Error(t *testing.T) {
+	st := NewStreamTokenizer(iotest.HalfReader(strings.NewReader("[1, tru]")))
+	var err error
+	for err == nil {
+		_, err = st.Next()
+	}
+	var tkErr *TokenizerError
+	if assert.ErrorAs(t, err, &tkErr) {
+		assert.Equal(t, UndefinedKeywordError, tkErr.ErrorType)
+		assert.Equal(t, []byte("tru"), tkErr.Letters)
+		assert.Equal(t, 4, tkErr.Start.Offset)
+		assert.Equal(t, 7, tkErr.End.Offset)
+	}
+}
+
+func TestStreamTokenizer_Next_Unterminated(t *testing.T) {
+	st := NewStreamTokenizer(iotest.OneByteReader(strings.NewReader("[\"abc")))
+	_, err := st.Next()
+	assert.NoError(t, err)
+	_, err = st.Next()
+	var tkErr *TokenizerError
+	if assert.ErrorAs(t, err, &tkErr) {
+		assert.Equal(t, UnterminatedStringError, tkErr.ErrorType)
+		assert.Equal(t, 1, tkErr.Start.Offset)
+	}
+}
+
+func TestStreamTokenizer_MaxTokenSize(t *testing.T) {
+	st := NewStreamTokenizer(iotest.OneByteReader(strings.NewReader("[\"" + strings.Repeat("a", 100) + "\"]")))
+	st.MaxTokenSize = 32
+
+	_, err := st.Next()
+	assert.NoError(t, err)
+	_, err = st.Next()
+	var tkErr *TokenizerError
+	if assert.ErrorAs(t, err, &tkErr) {
+		assert.Equal(t, TokenTooLongError, tkErr.ErrorType)
+		assert.Equal(t, 1, tkErr.Start.Offset)
+	}
+}
+
+func TestStreamTokenizer_Limits(t *testing.T) {
+	long := strings.Repeat("1", 3<<20)
+	var tests = []struct {
+		json    string
+		options TokenizerOptions
+		msg     string
+	}{
+		{`["` + long + `\"", 2]`, TokenizerOptions{MaxStringLength: 10}, "string exceeds the limit of 10 bytes"},
+		{`[` + long + `, 2]`, TokenizerOptions{MaxNumberLength: 10}, "number exceeds the limit of 10 bytes"},
+	}
+	for _, tt := range tests {
+		// the limit is hit long before MaxTokenSize, the rest of the token is skipped
+		st := NewStreamTokenizer(strings.NewReader(tt.json))
+		st.Options = tt.options
+		var types []TokenType
+		for {
+			token, err := st.Next()
+			if err != nil {
+				var tkErr *TokenizerError
+				if assert.ErrorAs(t, err, &tkErr) {
+					assert.Equal(t, LimitError, tkErr.ErrorType)
+					assert.Equal(t, tt.msg, tkErr.ErrorMessage)
+					assert.Equal(t, 1, tkErr.Start.Offset)
+				}
+				continue
+			}
+			types = append(types, token.Type)
+			if token.Type == TEof {
+				break
+			}
+		}
+		assert.Equal(t, []TokenType{TLSquareBracket, TComma, TNumber, TRSquareBracket, TEof}, types)
+	}
+}
+
+func TestStreamTokenizer_ReadError(t *testing.T) {
+	readErr := errors.New("connection reset")
+	st := NewStreamTokenizer(iotest.DataErrReader(iotest.ErrReader(readErr)))
+	_, err := st.Next()
+	assert.Equal(t, readErr, err)
+}
+
+func TestNewStreamParser(t *testing.T) {
+	json := "{\"msg\": \"hello\", \"members\": [\"tanaka\", \"sadako\"], \"sub\": {\"age\": 26}}"
+	ps := NewStreamParser(NewStreamTokenizer(iotest.OneByteReader(strings.NewReader(json))))
+	j, err := ps.Parse()
+	if !assert.NoError(t, err) {
+		return
+	}
+	mp, err := j.Map()
+	if assert.NoError(t, err) {
+		assert.Equal(t, "hello", mp["msg"])
+		assert.Equal(t, []interface{}{"tanaka", "sadako"}, mp["members"])
+		assert.Equal(t, map[string]interface{}{"age": float64(26)}, mp["sub"])
+	}
+	// only a window of the tokens is kept around
+	assert.LessOrEqual(t, len(ps.Tokens), 3)
+
+	ps = NewStreamParser(NewStreamTokenizer(strings.NewReader("[1, tru]")))
+	_, err = ps.Parse()
+	var tkErr *TokenizerError
+	if assert.ErrorAs(t, err, &tkErr) {
+		assert.Equal(t, UndefinedKeywordError, tkErr.ErrorType)
+	}
+}
+
+func TestStreamTokenizer_Encoding(t *testing.T) {
+	json := "{\"名前\": [\"😀\", 1],\n \"b\": true}"
+	want := NewTokenizer(json).MustTokenize()
+
+	for _, enc := range []Encoding{EncodingUTF8, EncodingUTF16LE, EncodingUTF32BE} {
+		st := NewStreamTokenizer(iotest.OneByteReader(bytes.NewReader(encode(json, enc, true))))
+		var tokens []Token
+		for {
+			token, err := st.Next()
+			if !assert.NoError(t, err, enc) {
+				return
+			}
+			tokens = append(tokens, token)
+			if token.Type == TEof {
+				break
+			}
+		}
+		assert.Equal(t, enc, st.Encoding)
+		assert.Equal(t, views(*want), views(tokens), enc)
+	}
+
+	// a lone surrogate is reported where it stands
+	src := append(encode("[\"a", EncodingUTF16BE, false), 0xDC, 0x00, 0, '"', 0, ']')
+	st := NewStreamTokenizer(iotest.OneByteReader(bytes.NewReader(src)))
+	st.Next()
+	_, err := st.Next()
+	if assert.Error(t, err) {
+		assert.Equal(t, InvalidEncodingError, err.(*TokenizerError).ErrorType)
+		assert.Equal(t, 3, err.(*TokenizerError).Start.Offset)
+	}
+}
+
+func TestStreamTokenizer_Position(t *testing.T) {
+	// only the line starts in the buffer are kept, not those of the whole stream
+	lines := 200000
+	st := NewStreamTokenizer(strings.NewReader("[\n" + strings.Repeat("1,\n", lines) + "2]"))
+	most := 0
+	for {
+		token, err := st.Next()
+		if !assert.NoError(t, err) {
+			return
+		}
+		if n := len(st.tk.src.lines.starts); n > most {
+			most = n
+		}
+		if token.Type == TRSquareBracket {
+			assert.Equal(t, Position{Offset: 2 + 3*lines + 1, Rune: 2 + 3*lines + 1, Line: lines + 2, Column: 2}, st.Position(token.Start))
+		}
+		if token.Type == TEof {
+			break
+		}
+	}
+	assert.LessOrEqual(t, most, DefaultStreamBufferSize)
+}
+
+func TestStreamTokenizer_MaxInputSize(t *testing.T) {
+	st := NewStreamTokenizer(strings.NewReader("[" + strings.Repeat("1, ", 1<<20) + "1]"))
+	st.Options.MaxInputSize = 10000
+	var err error
+	for err == nil {
+		_, err = st.Next()
+	}
+	if assert.Error(t, err) {
+		assert.Equal(t, LimitError, err.(*TokenizerError).ErrorType)
+		assert.Equal(t, 10000, err.(*TokenizerError).Start.Offset)
+	}
+	token, err := st.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, TEof, token.Type)
+}
@@ -1,6 +1,10 @@
 package gojson
 
-import "strconv"
+import (
+	"bytes"
+	"errors"
+	"strconv"
+)
 
 type TokenType int
 
@@ -20,15 +24,21 @@ const (
 	TRCurlyBracket
 	TLSquareBracket
 	TRSquareBracket
+	TComment
+	// TIdentifier is an unquoted JSON5 object key
+	TIdentifier
 )
 
 type Token struct {
 	Type TokenType
-	Data []rune
+	// Start <= token < End are byte offsets in the source. The token as written is
+	// Raw, and the Position method of the tokenizer works out lines, columns and runes.
+	Start int
+	End   int
 
-	// StartPos <= Data < EndPos
-	StartPos int
-	EndPos   int
+	// src is the input the token was read from, together with the options
+	// that decide how the escapes of a TString are decoded
+	src *source
 }
 
 func (tokenType TokenType) String() string {
@@ -59,77 +69,155 @@ func (tokenType TokenType) String() string {
 		return "TLSquareBracket"
 	case TRSquareBracket:
 		return "TRSquareBracket"
+	case TComment:
+		return "TComment"
+	case TIdentifier:
+		return "TIdentifier"
 	default:
 		return "TUnknown"
 	}
 }
 
-func NewToken(typ TokenType, dataStr string, startPos int, endPos int) *Token {
+// NewToken makes a token by hand, which is taken to be on a single line of ASCII.
+func NewToken(typ TokenType, dataStr string, start int, end int) *Token {
 	return &Token{
-		Type:     typ,
-		Data:     []rune(dataStr),
-		StartPos: startPos,
-		EndPos:   endPos,
+		Type:  typ,
+		Start: start,
+		End:   end,
+		src:   &source{data: []byte(dataStr), made: true},
+	}
+}
+
+// Raw returns the token as written in the source, without the quotes for TString.
+// It refers to the tokenizer's input, escape sequences are decoded by LoadAsString.
+func (t Token) Raw() []byte {
+	if t.src == nil {
+		return nil
+	}
+	if t.src.made {
+		return t.src.data
+	}
+	base := t.src.lines.start.Offset
+	raw := t.src.data[t.Start-base : t.End-base]
+	if t.Type == TString && len(raw) > 0 {
+		// the string may lack its closing quote, when it is not closed or has a bad escape
+		quote := raw[0]
+		raw = raw[1:]
+		if n := len(raw); n > 0 && raw[n-1] == quote && !escaped(raw, n-1) {
+			raw = raw[:n-1]
+		}
 	}
+	return raw
 }
 
-func (t *Token) LoadAsFloat64() float64 {
+// escaped reports whether b[i] is escaped by the backslashes in front of it.
+func escaped(b []byte, i int) bool {
+	n := 0
+	for i-n > 0 && b[i-n-1] == '\\' {
+		n++
+	}
+	return n%2 == 1
+}
+
+func (t *Token) LoadAsFloat64() (float64, error) {
 	if t.Type != TNumber {
-		panic(&TokenizerError{
-			ErrorType:    IllegalValueLoadingError,
-			ErrorMessage: "This Token is not TNumber",
-			Letters:      t.Data,
-			StartPos:     t.StartPos,
-			EndPos:       t.EndPos,
-		})
-	}
-	f, err := strconv.ParseFloat(string(t.Data), 64)
+		return 0, t.loadError("This Token is not TNumber")
+	}
+	raw := t.Raw()
+	s := string(raw)
+	if bytes.IndexAny(raw, "xX") >= 0 {
+		// a JSON5 hexadecimal integer, ParseFloat wants it with a binary exponent
+		s += "p0"
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	// out of range numbers are still valid JSON, ParseFloat rounds them to ±Inf or 0
+	if err != nil && !errors.Is(err, strconv.ErrRange) {
+		loadErr := t.loadError(err.Error())
+		loadErr.Err = err
+		return 0, loadErr
+	}
+	return f, nil
+}
+
+// LoadAsString decodes a TString, or the name of a TIdentifier.
+func (t *Token) LoadAsString() (string, error) {
+	if t.Type != TString && t.Type != TIdentifier {
+		return "", t.loadError("This Token is not TString")
+	}
+	raw := t.Raw()
+	if bytes.IndexByte(raw, '\\') < 0 {
+		return string(raw), nil
+	}
+	start := t.Start
+	if t.Type == TString {
+		// the body starts behind the opening quote
+		start++
+	}
+	var surrogates LoneSurrogatePolicy
+	var dialect Dialect
+	if t.src != nil {
+		surrogates, dialect = t.src.surrogates, t.src.dialect
+	}
+	return unquote(raw, start, t.position, surrogates, dialect)
+}
+
+func (t *Token) LoadAsBoolean() (bool, error) {
+	if t.Type != TTrue && t.Type != TFalse {
+		return false, t.loadError("This Token is neither TTrue nor TFalse")
+	}
+	return t.Type == TTrue, nil
+}
+
+func (t *Token) LoadAsNull() (interface{}, error) {
+	if t.Type != TNull {
+		return nil, t.loadError("This Token is not TNull")
+	}
+	return nil, nil
+}
+
+func (t *Token) MustLoadAsFloat64() float64 {
+	f, err := t.LoadAsFloat64()
 	if err != nil {
 		panic(err)
 	}
 	return f
 }
 
-func (t *Token) LoadAsString() string {
-	if t.Type != TString {
-		panic(&TokenizerError{
-			ErrorType:    IllegalValueLoadingError,
-			ErrorMessage: "This Token is not TString",
-			Letters:      t.Data,
-			StartPos:     t.StartPos,
-			EndPos:       t.EndPos,
-		})
-	}
-	return string(t.Data)
+func (t *Token) MustLoadAsString() string {
+	str, err := t.LoadAsString()
+	if err != nil {
+		panic(err)
+	}
+	return str
 }
 
-func (t *Token) LoadAsBoolean() bool {
-	if t.Type != TTrue && t.Type != TFalse {
-		panic(&TokenizerError{
-			ErrorType:    IllegalValueLoadingError,
-			ErrorMessage: "This Token is neither TTrue nor TFalse",
-			Letters:      t.Data,
-			StartPos:     t.StartPos,
-			EndPos:       t.EndPos,
-		})
-	}
-	switch string(t.Data) {
-	case "true":
-		return true
-	default:
-		return false
+func (t *Token) MustLoadAsBoolean() bool {
+	b, err := t.LoadAsBoolean()
+	if err != nil {
+		panic(err)
 	}
+	return b
 }
 
-func (t *Token) LoadAsNull() error {
-	if t.Type != TNull {
-		panic(&TokenizerError{
-			ErrorType:    IllegalValueLoadingError,
-			ErrorMessage: "This Token is TNull",
-			Letters:      t.Data,
-			StartPos:     t.StartPos,
-			EndPos:       t.EndPos,
-		})
-	}
-	return nil
+func (t *Token) MustLoadAsNull() interface{} {
+	null, err := t.LoadAsNull()
+	if err != nil {
+		panic(err)
+	}
+	return null
+}
+
+func (t *Token) loadError(msg string) *TokenizerError {
+	return &TokenizerError{
+		ErrorType:    IllegalValueLoadingError,
+		ErrorMessage: msg,
+		Letters:      t.Raw(),
+		Start:        t.position(t.Start),
+		End:          t.position(t.End),
+	}
+}
+
+// position works out the Position of the byte offset o in the source of the token.
+func (t Token) position(o int) Position {
+	return t.src.position(o)
 }
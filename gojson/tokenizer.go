@@ -1,334 +1,851 @@
 package gojson
 
 import (
-	"strings"
+	"bytes"
+	"fmt"
 	"unicode"
+	"unicode/utf8"
 )
 
+// LoneSurrogatePolicy decides what happens to a \uXXXX escape that encodes
+// half of a UTF-16 surrogate pair without its partner.
+type LoneSurrogatePolicy int
+
+const (
+	// LoneSurrogateError rejects the escape with an InvalidEscapeError.
+	LoneSurrogateError LoneSurrogatePolicy = iota
+	// LoneSurrogateReplace decodes the escape as U+FFFD.
+	LoneSurrogateReplace
+	// LoneSurrogateKeep keeps the surrogate code point as is.
+	LoneSurrogateKeep
+)
+
+// Dialect is the flavour of JSON to read.
+type Dialect int
+
+const (
+	// DialectJSON is strict JSON as defined by RFC 8259.
+	DialectJSON Dialect = iota
+	// DialectJSON5 is JSON5 (https://spec.json5.org): comments, single quoted strings,
+	// unquoted keys, trailing commas, hexadecimal numbers, Infinity and NaN and more.
+	DialectJSON5
+)
+
+type TokenizerOptions struct {
+	Dialect        Dialect
+	LoneSurrogates LoneSurrogatePolicy
+	// Comments allows // line and /* block */ comments, which are handed out as TComment
+	Comments bool
+
+	// Limits on untrusted input, in bytes as written in the source. 0 means no limit.
+	// Exceeding one is a LimitError, and the input ends with it for MaxInputSize.
+	MaxInputSize    int
+	MaxStringLength int
+	MaxNumberLength int
+}
+
 func NewTokenizer(text string) *Tokenizer {
+	return NewTokenizerBytes([]byte(text))
+}
+
+// NewTokenizerBytes tokenizes src in place, without copying it.
+// The tokens refer back to src, so it must not be modified while they are in use.
+//
+// The encoding of src is detected with DetectEncoding and a byte order mark is skipped.
+// UTF-16 and UTF-32 input is transcoded to UTF-8 first, positions then refer to the UTF-8 text.
+func NewTokenizerBytes(src []byte) *Tokenizer {
+	enc, bomSize := DetectEncoding(src)
+	src = src[bomSize:]
+	if enc != EncodingUTF8 {
+		src, _ = transcode(make([]byte, 0, len(src)), src, enc, true)
+	}
 	return &Tokenizer{
-		Raw:     &text,
-		Letters: []rune(text),
-		Pos:     0,
+		Source:   src,
+		Pos:      0,
+		Encoding: enc,
 	}
 }
 
 type Tokenizer struct {
-	Raw     *string
-	Letters []rune
-	Pos     int
+	Source []byte
+	// Pos is a byte offset into Source
+	Pos int
+	// SourceName is the file name or the like reported in errors
+	SourceName string
+	// Encoding is the detected encoding of the input, Source always holds UTF-8
+	Encoding Encoding
+
+	Options TokenizerOptions
+
+	// base is the offset of Source in the whole input, it is not 0 for a StreamTokenizer
+	base int
+	// src is the source of the tokens, it locates them and holds the options they are read with
+	src *source
+	// ended is set once the input has been cut off at MaxInputSize
+	ended bool
+}
+
+// source returns the source of the tokens read next. When the options have been
+// changed since, the tokens read before keep the ones they were read with.
+func (t *Tokenizer) source() *source {
+	if t.src == nil {
+		t.src = newSource(t.Source, Position{Offset: t.base, Rune: t.base, Line: 1, Column: 1}, t.Options)
+	} else if t.src.surrogates != t.Options.LoneSurrogates || t.src.dialect != t.Options.Dialect {
+		t.src = newSource(t.src.data, t.src.lines.start, t.Options)
+	}
+	return t.src
+}
+
+// position returns the Position of Source[pos].
+func (t *Tokenizer) position(pos int) Position {
+	return t.Position(t.base + pos)
+}
+
+// Position works out the line, column and rune offset of the byte offset o
+// of a token or node read by t or by the parser of its tokens.
+func (t *Tokenizer) Position(o int) Position {
+	return t.source().position(o)
 }
 
 func (t *Tokenizer) Letter() rune {
-	return t.Letters[t.Pos]
+	return t.LetterAt(t.Pos)
 }
 
 func (t *Tokenizer) LetterAt(pos int) rune {
-	return t.Letters[pos]
+	if c := t.Source[pos]; c < utf8.RuneSelf {
+		return rune(c)
+	}
+	r, _ := utf8.DecodeRune(t.Source[pos:])
+	return r
 }
 
 func (t *Tokenizer) NextLetter() rune {
-	return t.Letters[t.Pos+1]
+	_, size := utf8.DecodeRune(t.Source[t.Pos:])
+	return t.LetterAt(t.Pos + size)
 }
 
 func (t *Tokenizer) PrevLetter() rune {
-	return t.Letters[t.Pos-1]
+	r, _ := utf8.DecodeLastRune(t.Source[:t.Pos])
+	return r
 }
 
 func (t *Tokenizer) GoNext() {
-	t.Pos++
+	if t.Source[t.Pos] < utf8.RuneSelf {
+		t.Pos++
+		return
+	}
+	_, size := utf8.DecodeRune(t.Source[t.Pos:])
+	t.Pos += size
 }
 
 func (t *Tokenizer) GoPrev() {
-	t.Pos--
+	_, size := utf8.DecodeLastRune(t.Source[:t.Pos])
+	t.Pos -= size
 }
 
 func (t *Tokenizer) IsEof() bool {
-	return t.Pos >= len(t.Letters)
+	return t.Pos >= len(t.Source)
 }
 
 func (t *Tokenizer) ConsumeWhiteSpace() Token {
-	var data []rune
-
-	startPos := t.Pos
-	for len(t.Letters) > t.Pos {
-		if unicode.IsSpace(t.Letter()) {
-			data = append(data, t.Letter())
-			t.GoNext()
-		} else {
-			break
-		}
+	start := t.Pos
+	for !t.IsEof() && t.isSpace(t.Letter()) {
+		t.GoNext()
 	}
-	endPos := t.Pos
+	return t.token(TWhiteSpace, start)
+}
 
-	return Token{
-		Type:     TWhiteSpace,
-		Data:     data,
-		StartPos: startPos,
-		EndPos:   endPos,
-	}
+// isSpace reports whether r is whitespace, JSON5 also counts the byte order mark in.
+func (t *Tokenizer) isSpace(r rune) bool {
+	return unicode.IsSpace(r) || r == '\uFEFF' && t.Options.Dialect == DialectJSON5
 }
 
 func (t *Tokenizer) ConsumeKeyword() (Token, error) {
-	var data []rune
-
-	startPos := t.Pos
-	for len(t.Letters) > t.Pos {
-		if unicode.IsSpace(t.Letter()) || t.Letter() == ':' || t.Letter() == ',' || t.Letter() == ']' || t.Letter() == '}' {
+	if t.Options.Dialect == DialectJSON5 {
+		return t.consumeIdentifier()
+	}
+	start := t.Pos
+	for !t.IsEof() {
+		letter := t.Letter()
+		if unicode.IsSpace(letter) || letter == ':' || letter == ',' || letter == ']' || letter == '}' ||
+			letter == '/' && t.Options.Comments {
 			break
-		} else {
-			data = append(data, t.Letter())
-			t.GoNext()
 		}
+		t.GoNext()
 	}
-	endPos := t.Pos
 
-	var tokenType TokenType
-	var err error
-	switch string(data) {
+	token := t.token(TUnknown, start)
+	switch string(token.Raw()) {
 	case "true":
-		tokenType = TTrue
-		err = nil
+		token.Type = TTrue
 	case "false":
-		tokenType = TFalse
-		err = nil
+		token.Type = TFalse
 	case "null":
-		tokenType = TNull
-		err = nil
+		token.Type = TNull
 	default:
-		tokenType = TUnknown
-		err = &TokenizerError{
+		return token, &TokenizerError{
 			ErrorType:    UndefinedKeywordError,
 			ErrorMessage: "undefined keyword",
-			Letters:      data,
-			StartPos:     startPos,
-			EndPos:       endPos,
+			Letters:      token.Raw(),
+			Start:        t.Position(token.Start),
+			End:          t.Position(token.End),
 		}
 	}
+	return token, nil
+}
 
-	return Token{
-		Type:     tokenType,
-		Data:     data,
-		StartPos: startPos,
-		EndPos:   endPos,
-	}, err
+// consumeIdentifier reads a JSON5 identifier. true, false and null are keywords,
+// Infinity and NaN numbers and anything else a TIdentifier.
+func (t *Tokenizer) consumeIdentifier() (Token, error) {
+	start := t.Pos
+	for !t.IsEof() {
+		r, size := t.Letter(), 0
+		if r == '\\' {
+			// a unicode escape such as \u0061
+			var msg string
+			if t.Pos+1 < len(t.Source) && t.Source[t.Pos+1] == 'u' {
+				r, size, msg = decodeHex4(t.Source, t.Pos)
+			} else {
+				size, msg = 1, "invalid escape sequence in identifier"
+			}
+			if msg == "" && !isIdentifierLetter(r, t.Pos == start) {
+				msg = "escaped character is not allowed in identifier"
+			}
+			if msg != "" {
+				escapePos := t.Pos
+				escapeStart := t.position(escapePos)
+				t.Pos += size
+				escapeErr := &TokenizerError{
+					ErrorType:    InvalidEscapeError,
+					ErrorMessage: msg,
+					Letters:      t.Source[escapePos:t.Pos],
+					Start:        escapeStart,
+					End:          t.position(t.Pos),
+				}
+				t.skipIdentifier()
+				return t.token(TIdentifier, start), escapeErr
+			}
+			t.Pos += size
+			continue
+		}
+		if !isIdentifierLetter(r, t.Pos == start) {
+			break
+		}
+		t.GoNext()
+	}
+
+	token := t.token(TIdentifier, start)
+	switch string(token.Raw()) {
+	case "true":
+		token.Type = TTrue
+	case "false":
+		token.Type = TFalse
+	case "null":
+		token.Type = TNull
+	case "Infinity", "NaN":
+		token.Type = TNumber
+	}
+	return token, nil
+}
+
+// skipIdentifier moves past the rest of an identifier that stopped at an invalid escape,
+// so that the letters behind it are not read as another token.
+func (t *Tokenizer) skipIdentifier() {
+	for !t.IsEof() {
+		if r := t.Letter(); r != '\\' && !isIdentifierLetter(r, false) {
+			return
+		}
+		t.GoNext()
+	}
 }
 
+// isIdentifierLetter reports whether r may appear in an ECMAScript identifier,
+// or at its start when first is set.
+func isIdentifierLetter(r rune, first bool) bool {
+	switch {
+	case r == '$' || r == '_' || unicode.IsLetter(r) || unicode.Is(unicode.Nl, r):
+		return true
+	case first:
+		return false
+	default:
+		return unicode.In(r, unicode.Mn, unicode.Mc, unicode.Nd, unicode.Pc) || r == '\u200C' || r == '\u200D'
+	}
+}
+
+// ConsumeNumber reads a number as defined by RFC 8259:
+//
+//	number = [ minus ] int [ frac ] [ exp ]
+//	int    = zero / ( digit1-9 *DIGIT )
+//	frac   = decimal-point 1*DIGIT
+//	exp    = e [ minus / plus ] 1*DIGIT
+//
+// DialectJSON5 reads numbers as described by scanNumber5 instead.
 func (t *Tokenizer) ConsumeNumber() (Token, error) {
-	var data []rune
-	startPos := t.Pos
+	start := t.Pos
 
-	for len(t.Letters) > t.Pos {
-		if unicode.IsDigit(t.Letter()) || t.Letter() == '.' || t.Letter() == '-' || t.Letter() == '+' {
-			data = append(data, t.Letter())
-			t.GoNext()
+	var msg string
+	if t.Options.Dialect == DialectJSON5 {
+		msg = t.scanNumber5()
+	} else {
+		msg = t.scanNumber()
+	}
+
+	if msg != "" {
+		// swallow the rest of the malformed literal so that it is reported as a whole
+		for !t.IsEof() && isNumberLetter(t.Source[t.Pos]) {
+			t.Pos++
+		}
+		token := t.token(TNumber, start)
+		return token, &TokenizerError{
+			ErrorType:    InvalidDataError,
+			ErrorMessage: msg,
+			Letters:      token.Raw(),
+			Start:        t.Position(token.Start),
+			End:          t.Position(token.End),
+		}
+	}
+
+	token := t.token(TNumber, start)
+	if max := t.Options.MaxNumberLength; max > 0 && len(token.Raw()) > max {
+		return token, &TokenizerError{
+			ErrorType:    LimitError,
+			ErrorMessage: fmt.Sprintf("number exceeds the limit of %v bytes", max),
+			Letters:      head(token.Raw()),
+			Start:        t.Position(token.Start),
+			End:          t.Position(token.End),
+		}
+	}
+	return token, nil
+}
+
+// scanNumber moves behind a RFC 8259 number, it returns what is wrong with a malformed one.
+func (t *Tokenizer) scanNumber() string {
+	var msg string
+	switch t.Source[t.Pos] {
+	case '+':
+		msg = "Plus sign is not allowed at the beginning."
+		t.Pos++
+	case '-':
+		t.Pos++
+	}
+
+	// int
+	if msg == "" {
+		switch {
+		case t.IsEof():
+			msg = "Minus must be followed by a digit."
+		case t.Source[t.Pos] == '0':
+			t.Pos++
+			if t.isDigit() {
+				msg = "Leading zeros are not allowed."
+			}
+		case t.isDigit():
+			t.consumeDigits()
+		case t.Source[t.Pos] == '.':
+			msg = "Dot must be preceded by a digit."
+		default:
+			msg = "Minus must be followed by a digit."
+		}
+	}
+
+	// frac
+	if msg == "" && t.is('.') {
+		t.Pos++
+		if !t.isDigit() {
+			msg = "Dot must be followed by a digit."
 		} else {
-			break
+			t.consumeDigits()
 		}
 	}
-	endPos := t.Pos
 
-	var err error
-	if data[0] == '.' {
-		err = &TokenizerError{
-			ErrorType:    InvalidDataError,
-			ErrorMessage: "Dot must not be at the beginning.",
-			Letters:      data,
-			StartPos:     startPos,
-			EndPos:       endPos,
+	// exp
+	if msg == "" && (t.is('e') || t.is('E')) {
+		t.Pos++
+		if t.is('+') || t.is('-') {
+			t.Pos++
 		}
-	} else if len(string(data))-len(strings.ReplaceAll(string(data), ".", "")) > 1 {
-		err = &TokenizerError{
-			ErrorType:    InvalidDataError,
-			ErrorMessage: "There must not be more than one dot.",
-			Letters:      data,
-			StartPos:     startPos,
-			EndPos:       endPos,
+		if !t.isDigit() {
+			msg = "Exponent must contain at least one digit."
+		} else {
+			t.consumeDigits()
 		}
-	} else if strings.Contains(string(data), "-") && data[0] != '-' {
-		err = &TokenizerError{
-			ErrorType:    InvalidDataError,
-			ErrorMessage: "Minus must be at the beginning.",
-			Letters:      data,
-			StartPos:     startPos,
-			EndPos:       endPos,
+	}
+
+	if msg == "" && t.is('.') {
+		msg = "There must not be more than one dot."
+	}
+	return msg
+}
+
+// scanNumber5 moves behind a JSON5 number. Unlike JSON, it may start with a plus sign,
+// be hexadecimal, be Infinity or NaN, and lack the digits before or after its dot.
+func (t *Tokenizer) scanNumber5() string {
+	if t.is('+') || t.is('-') {
+		t.Pos++
+	}
+
+	switch {
+	case bytes.HasPrefix(t.Source[t.Pos:], []byte("Infinity")):
+		t.Pos += len("Infinity")
+		return ""
+	case bytes.HasPrefix(t.Source[t.Pos:], []byte("NaN")):
+		t.Pos += len("NaN")
+		return ""
+	case t.is('0') && t.Pos+1 < len(t.Source) && (t.Source[t.Pos+1] == 'x' || t.Source[t.Pos+1] == 'X'):
+		t.Pos += 2
+		_, n := decodeHex(t.Source[t.Pos:], len(t.Source)-t.Pos)
+		if n == 0 {
+			return "Hexadecimal number must contain at least one digit."
 		}
-	} else if len(string(data))-len(strings.ReplaceAll(string(data), "-", "")) > 1 {
-		err = &TokenizerError{
-			ErrorType:    InvalidDataError,
-			ErrorMessage: "There must not be more than one minus.",
-			Letters:      data,
-			StartPos:     startPos,
-			EndPos:       endPos,
+		t.Pos += n
+		return ""
+	}
+
+	// int, it may be left out before a dot
+	digits := false
+	switch {
+	case t.is('0'):
+		t.Pos++
+		if t.isDigit() {
+			return "Leading zeros are not allowed."
 		}
-	} else if strings.Contains(string(data), "+") && data[0] != '+' {
-		err = &TokenizerError{
-			ErrorType:    InvalidDataError,
-			ErrorMessage: "Plus must be at the beginning.",
-			Letters:      data,
-			StartPos:     startPos,
-			EndPos:       endPos,
+		digits = true
+	case t.isDigit():
+		t.consumeDigits()
+		digits = true
+	}
+
+	// frac, its digits may be left out behind the int
+	if t.is('.') {
+		t.Pos++
+		if t.isDigit() {
+			t.consumeDigits()
+			digits = true
 		}
-	} else if len(string(data))-len(strings.ReplaceAll(string(data), "+", "")) > 1 {
-		err = &TokenizerError{
-			ErrorType:    InvalidDataError,
-			ErrorMessage: "There must not be more than one plus.",
-			Letters:      data,
-			StartPos:     startPos,
-			EndPos:       endPos,
+	}
+	if !digits {
+		return "Number must contain at least one digit."
+	}
+
+	// exp
+	if t.is('e') || t.is('E') {
+		t.Pos++
+		if t.is('+') || t.is('-') {
+			t.Pos++
+		}
+		if !t.isDigit() {
+			return "Exponent must contain at least one digit."
 		}
+		t.consumeDigits()
 	}
 
-	return Token{
-		Type:     TNumber,
-		Data:     data,
-		StartPos: startPos,
-		EndPos:   endPos,
-	}, err
+	if t.is('.') {
+		return "There must not be more than one dot."
+	}
+	return ""
 }
 
-func (t *Tokenizer) ConsumeString() (Token, error) {
-	var data []rune
+func (t *Tokenizer) is(c byte) bool {
+	return !t.IsEof() && t.Source[t.Pos] == c
+}
 
-	var quotationCount int
+func (t *Tokenizer) isDigit() bool {
+	return !t.IsEof() && isDigit(t.Source[t.Pos])
+}
 
-	startPos := t.Pos
-	for len(t.Letters) > t.Pos {
-		if t.Letter() == '"' {
-			// escaped quotation
-			if t.Pos != 0 && t.PrevLetter() == '\\' {
-				data = append(data, t.Letter())
-				t.GoNext()
-			} else {
-				quotationCount++
-				t.GoNext()
-				if quotationCount >= 2 {
-					break
+func (t *Tokenizer) consumeDigits() {
+	for t.isDigit() {
+		t.Pos++
+	}
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func isNumberLetter(c byte) bool {
+	return isDigit(c) || c == '.' || c == '-' || c == '+' || c == 'e' || c == 'E'
+}
+
+// ConsumeString reads a string literal. Escape sequences are validated
+// here but only decoded once the token is loaded with LoadAsString.
+func (t *Tokenizer) ConsumeString() (Token, error) {
+	start := t.Pos
+	// reported once the closing quote is found
+	var charErr *TokenizerError
+	// consume opening '"', or "'" in JSON5
+	quote := t.Source[t.Pos]
+	t.Pos++
+	for !t.IsEof() {
+		c := t.Source[t.Pos]
+		switch {
+		case c == quote:
+			// consume closing quote
+			t.Pos++
+			token := t.token(TString, start)
+			if max := t.Options.MaxStringLength; max > 0 && len(token.Raw()) > max {
+				return token, &TokenizerError{
+					ErrorType:    LimitError,
+					ErrorMessage: fmt.Sprintf("string exceeds the limit of %v bytes", max),
+					Letters:      head(token.Raw()),
+					Start:        t.Position(token.Start),
+					End:          t.Position(token.End),
 				}
 			}
-		} else {
-			data = append(data, t.Letter())
+			if charErr != nil {
+				return token, charErr
+			}
+			return token, nil
+		case c < 0x20 && (t.Options.Dialect != DialectJSON5 || c == '\n' || c == '\r'):
+			if charErr == nil {
+				ctrlStart := t.position(t.Pos)
+				charErr = &TokenizerError{
+					ErrorType:    UnexpectedCharacterError,
+					ErrorMessage: fmt.Sprintf("control character U+%04X must be escaped", c),
+					Letters:      t.Source[t.Pos : t.Pos+1],
+					Start:        ctrlStart,
+					End:          t.position(t.Pos + 1),
+				}
+			}
+			t.Pos++
+		case c >= utf8.RuneSelf:
+			if charErr == nil {
+				charErr = t.encodingError(t.Pos)
+			}
 			t.GoNext()
+		case c == '\\':
+			_, size, msg := decodeEscape(t.Source, t.Pos, t.Options.LoneSurrogates, t.Options.Dialect)
+			if msg != "" {
+				escapePos := t.Pos
+				escapeStart := t.position(escapePos)
+				t.Pos += size
+				return t.token(TString, start), &TokenizerError{
+					ErrorType:    InvalidEscapeError,
+					ErrorMessage: msg,
+					Letters:      t.Source[escapePos:t.Pos],
+					Start:        escapeStart,
+					End:          t.position(t.Pos),
+				}
+			}
+			t.Pos += size
+		default:
+			t.Pos++
 		}
 	}
-	endPos := t.Pos
 
-	var err error
-	return Token{
-		Type:     TString,
-		Data:     data,
-		StartPos: startPos,
-		EndPos:   endPos,
-	}, err
+	token := t.token(TString, start)
+	return token, &TokenizerError{
+		ErrorType:    UnterminatedStringError,
+		ErrorMessage: "string is not closed",
+		Letters:      head(t.Source[start:t.Pos]),
+		Start:        t.position(start),
+		End:          t.position(start + 1),
+	}
 }
 
-func (t *Tokenizer) Tokenize() *[]Token {
-	var tokens []Token
+// encodingError returns the error for a malformed UTF-8 sequence at Source[pos], or nil for a well-formed one.
+func (t *Tokenizer) encodingError(pos int) *TokenizerError {
+	if r, size := utf8.DecodeRune(t.Source[pos:]); r != utf8.RuneError || size != 1 {
+		return nil
+	}
+	msg := fmt.Sprintf("invalid UTF-8 byte 0x%02X", t.Source[pos])
+	if t.Encoding != EncodingUTF8 {
+		msg = fmt.Sprintf("invalid %v sequence", t.Encoding)
+	}
+	start := t.position(pos)
+	return &TokenizerError{
+		ErrorType:    InvalidEncodingError,
+		ErrorMessage: msg,
+		Letters:      t.Source[pos : pos+1],
+		Start:        start,
+		End:          t.position(pos + 1),
+	}
+}
 
-	for len(t.Letters) > t.Pos {
-		if t.IsEof() {
-			return &tokens
+// checkEncoding returns the error for the first malformed UTF-8 sequence in Source[from:to].
+func (t *Tokenizer) checkEncoding(from int, to int) *TokenizerError {
+	if utf8.Valid(t.Source[from:to]) {
+		return nil
+	}
+	for pos := from; pos < to; {
+		if err := t.encodingError(pos); err != nil {
+			return err
 		}
+		_, size := utf8.DecodeRune(t.Source[pos:to])
+		pos += size
+	}
+	return nil
+}
 
-		if t.Letter() == '"' {
-			token, err := t.ConsumeString()
-			if err != nil {
-				panic(err)
-			}
-			tokens = append(tokens, token)
-			continue
-		}
+// head cuts b down to the first few bytes, enough to recognise a long token in an error.
+func head(b []byte) []byte {
+	if len(b) > 16 {
+		return b[:16]
+	}
+	return b
+}
 
-		if unicode.IsDigit(t.Letter()) || t.Letter() == '-' || t.Letter() == '+' {
-			token, err := t.ConsumeNumber()
-			if err != nil {
-				panic(err)
-			}
-			tokens = append(tokens, token)
-			continue
-		}
+// token makes a token of the source from Source[start] to Source[t.Pos].
+func (t *Tokenizer) token(typ TokenType, start int) Token {
+	return Token{
+		Type:  typ,
+		Start: t.base + start,
+		End:   t.base + t.Pos,
+		src:   t.source(),
+	}
+}
 
-		if unicode.IsLetter(t.Letter()) {
-			token, err := t.ConsumeKeyword()
-			if err != nil {
-				panic(err)
-			}
-			tokens = append(tokens, token)
-			continue
-		}
+// Next skips whitespace and returns the token that follows it.
+// Once the input is exhausted it keeps returning a TEof token.
+// A malformed token is returned with its error, and Next can be called again
+// to carry on behind it.
+func (t *Tokenizer) Next() (Token, error) {
+	token, err := t.next()
+	if tkErr, ok := err.(*TokenizerError); ok {
+		tkErr.SourceName = t.SourceName
+	}
+	return token, err
+}
 
-		if unicode.IsSpace(t.Letter()) {
-			// ignore whitespace
-			// tokens = append(tokens, t.ConsumeWhiteSpace())
-			t.ConsumeWhiteSpace()
-			continue
+func (t *Tokenizer) next() (Token, error) {
+	if t.ended {
+		return t.token(TEof, t.Pos), nil
+	}
+	if max := t.Options.MaxInputSize; max > 0 && t.base+len(t.Source) > max {
+		// the input is cut off at the limit, which lies ahead of Pos
+		t.ended = true
+		t.Pos = max - t.base
+		return t.token(TUnknown, t.Pos), &TokenizerError{
+			ErrorType:    LimitError,
+			ErrorMessage: fmt.Sprintf("input exceeds the limit of %v bytes", max),
+			Letters:      head(t.Source[t.Pos:]),
+			Start:        t.position(t.Pos),
+			End:          t.position(t.Pos),
 		}
+	}
 
-		if t.Letter() == ',' {
-			tokens = append(tokens, Token{
-				Type:     TComma,
-				Data:     []rune{t.Letter()},
-				StartPos: t.Pos,
-				EndPos:   t.Pos + 1,
-			})
-			t.GoNext()
-			continue
+	// ignore whitespace
+	if !t.IsEof() && t.isSpace(t.Letter()) {
+		t.ConsumeWhiteSpace()
+	}
+
+	if t.IsEof() {
+		return t.token(TEof, t.Pos), nil
+	}
+
+	switch c := t.Source[t.Pos]; {
+	case c == '"' || c == '\'' && t.Options.Dialect == DialectJSON5:
+		return t.ConsumeString()
+	case isDigit(c) || c == '-' || c == '+' || c == '.':
+		return t.ConsumeNumber()
+	case c == ',':
+		return t.ConsumeSymbol(TComma), nil
+	case c == ':':
+		return t.ConsumeSymbol(TColon), nil
+	case c == '{':
+		return t.ConsumeSymbol(TLCurlyBracket), nil
+	case c == '}':
+		return t.ConsumeSymbol(TRCurlyBracket), nil
+	case c == '[':
+		return t.ConsumeSymbol(TLSquareBracket), nil
+	case c == ']':
+		return t.ConsumeSymbol(TRSquareBracket), nil
+	case c == '/' && (t.Options.Comments || t.Options.Dialect == DialectJSON5):
+		return t.ConsumeComment()
+	case unicode.IsLetter(t.Letter()):
+		return t.ConsumeKeyword()
+	case t.Options.Dialect == DialectJSON5 && (c == '\\' || isIdentifierLetter(t.Letter(), true)):
+		return t.ConsumeKeyword()
+	default:
+		if err := t.encodingError(t.Pos); err != nil {
+			start := t.Pos
+			t.Pos++
+			return t.token(TUnknown, start), err
+		}
+		token := t.ConsumeSymbol(TUnknown)
+		return token, &TokenizerError{
+			ErrorType:    UnexpectedCharacterError,
+			ErrorMessage: "unexpected character",
+			Letters:      token.Raw(),
+			Start:        t.Position(token.Start),
+			End:          t.Position(token.End),
 		}
+	}
+}
 
-		if t.Letter() == ':' {
-			tokens = append(tokens, Token{
-				Type:     TColon,
-				Data:     []rune{t.Letter()},
-				StartPos: t.Pos,
-				EndPos:   t.Pos + 1,
-			})
-			t.GoNext()
-			continue
+// ConsumeComment reads a // comment up to the end of its line, or a /* */ comment.
+func (t *Tokenizer) ConsumeComment() (Token, error) {
+	start := t.Pos
+	// consume '/'
+	t.Pos++
+
+	switch {
+	case t.is('/'):
+		for !t.IsEof() && t.Source[t.Pos] != '\n' && t.Source[t.Pos] != '\r' {
+			t.Pos++
+		}
+		return t.comment(start)
+	case !t.is('*'):
+		token := t.token(TUnknown, start)
+		return token, &TokenizerError{
+			ErrorType:    UnexpectedCharacterError,
+			ErrorMessage: "expected `//` or `/*`",
+			Letters:      token.Raw(),
+			Start:        t.Position(token.Start),
+			End:          t.Position(token.End),
 		}
+	}
 
-		// curly bracket
-		if t.Letter() == '{' || t.Letter() == '}' {
-			var tokenType TokenType
-			if t.Letter() == '{' {
-				tokenType = TLCurlyBracket
-			} else {
-				tokenType = TRCurlyBracket
-			}
-			tokens = append(tokens, Token{
-				Type:     tokenType,
-				Data:     []rune{t.Letter()},
-				StartPos: t.Pos,
-				EndPos:   t.Pos + 1,
-			})
-			t.GoNext()
-			continue
+	// consume '*'
+	t.Pos++
+	if end := bytes.Index(t.Source[t.Pos:], []byte("*/")); end >= 0 {
+		t.Pos += end + 2
+		return t.comment(start)
+	}
+
+	t.Pos = len(t.Source)
+	token := t.token(TComment, start)
+	return token, &TokenizerError{
+		ErrorType:    UnterminatedCommentError,
+		ErrorMessage: "comment is not closed",
+		Letters:      head(token.Raw()),
+		Start:        t.position(start),
+		End:          t.position(start + 2),
+	}
+}
+
+// comment makes a TComment token from start to t.Pos, rejecting malformed UTF-8 in it.
+func (t *Tokenizer) comment(start int) (Token, error) {
+	if err := t.checkEncoding(start, t.Pos); err != nil {
+		return t.token(TComment, start), err
+	}
+	return t.token(TComment, start), nil
+}
+
+// ConsumeSymbol consumes the current letter as a single letter token.
+func (t *Tokenizer) ConsumeSymbol(typ TokenType) Token {
+	start := t.Pos
+	t.GoNext()
+	return t.token(typ, start)
+}
+
+// Tokenize reads tokens up to and including TEof.
+// It stops at the first malformed token and returns its *TokenizerError.
+func (t *Tokenizer) Tokenize() (*[]Token, error) {
+	var tokens tokenList
+
+	for {
+		token, err := t.Next()
+		if err != nil {
+			return tokens.all(), err
+		}
+		tokens.add(token)
+		if token.Type == TEof {
+			return tokens.all(), nil
 		}
+	}
+}
 
-		// square bracket
-		if t.Letter() == '[' || t.Letter() == ']' {
-			var tokenType TokenType
-			if t.Letter() == '[' {
-				tokenType = TLSquareBracket
-			} else {
-				tokenType = TRSquareBracket
+// TokenizeAll reads tokens up to and including TEof, carrying on past
+// malformed tokens. Those are kept in the result as TUnknown, and every
+// *TokenizerError is returned in input order.
+func (t *Tokenizer) TokenizeAll() (*[]Token, []*TokenizerError) {
+	var tokens tokenList
+	var errs []*TokenizerError
+
+	for {
+		token, err := t.Next()
+		if err != nil {
+			errs = append(errs, err.(*TokenizerError))
+			if err.(*TokenizerError).ErrorType == InvalidEscapeError && token.Type == TString {
+				t.skipString(t.Source[token.Start-t.base])
 			}
-			tokens = append(tokens, Token{
-				Type:     tokenType,
-				Data:     []rune{t.Letter()},
-				StartPos: t.Pos,
-				EndPos:   t.Pos + 1,
-			})
-			t.GoNext()
-			continue
+			token.Type = TUnknown
+		}
+		tokens.add(token)
+		if token.Type == TEof {
+			return tokens.all(), errs
 		}
 	}
+}
+
+// maxTokenChunk is the size the chunks of a tokenList grow to.
+const maxTokenChunk = 4096
 
-	tokens = append(tokens, Token{
-		Type:     TEof,
-		Data:     []rune{},
-		StartPos: t.Pos,
-		EndPos:   t.Pos + 1,
-	})
+// tokenList gathers tokens in chunks, which are copied into a slice of the right size
+// once all are read. Growing a single slice instead allocates several times its final size.
+type tokenList struct {
+	full  [][]Token
+	chunk []Token
+}
+
+func (l *tokenList) add(token Token) {
+	if len(l.chunk) == cap(l.chunk) {
+		size := 2 * cap(l.chunk)
+		if size < 16 {
+			size = 16
+		} else if size > maxTokenChunk {
+			size = maxTokenChunk
+		}
+		if l.chunk != nil {
+			l.full = append(l.full, l.chunk)
+		}
+		l.chunk = make([]Token, 0, size)
+	}
+	l.chunk = append(l.chunk, token)
+}
 
+// all returns the tokens added, in order.
+func (l *tokenList) all() *[]Token {
+	if len(l.full) == 0 {
+		return &l.chunk
+	}
+	n := len(l.chunk)
+	for _, chunk := range l.full {
+		n += len(chunk)
+	}
+	tokens := make([]Token, 0, n)
+	for _, chunk := range l.full {
+		tokens = append(tokens, chunk...)
+	}
+	tokens = append(tokens, l.chunk...)
 	return &tokens
 }
+
+// skipString moves past the rest of a string that stopped at an invalid escape,
+// so that its contents are not read as tokens. It reports whether it found the
+// end of the string rather than the end of Source, in front of an escape that
+// may go on behind it.
+func (t *Tokenizer) skipString(quote byte) bool {
+	for !t.IsEof() {
+		switch c := t.Source[t.Pos]; {
+		case c == quote:
+			t.Pos++
+			return true
+		case c == '\\':
+			if t.Pos+1 >= len(t.Source) || t.Source[t.Pos+1] == '\r' && t.Pos+2 >= len(t.Source) {
+				return false
+			}
+			t.Pos += 2
+			// a JSON5 line continuation with CRLF
+			if t.Source[t.Pos-1] == '\r' && t.Source[t.Pos] == '\n' {
+				t.Pos++
+			}
+		case c == '\n' || c == '\r':
+			// the string is not closed on this line
+			return true
+		default:
+			t.Pos++
+		}
+	}
+	return false
+}
+
+// MustTokenize is like Tokenize but panics on a malformed token.
+func (t *Tokenizer) MustTokenize() *[]Token {
+	tokens, err := t.Tokenize()
+	if err != nil {
+		panic(err)
+	}
+	return tokens
+}
@@ -1,10 +1,37 @@
 package gojson
 
 import (
+	"encoding/binary"
 	"github.com/stretchr/testify/assert"
+	"math"
+	"strconv"
+	"strings"
 	"testing"
+	"unicode"
+	"unicode/utf16"
 )
 
+// linePos is the Position at offset in a single line of ASCII text
+func linePos(offset int) Position {
+	return Position{Offset: offset, Rune: offset, Line: 1, Column: offset + 1}
+}
+
+// tokenView is what a token stands for, to compare tokens of different sources.
+type tokenView struct {
+	Type  TokenType
+	Raw   []byte
+	Start int
+	End   int
+}
+
+func views(tokens []Token) []tokenView {
+	out := make([]tokenView, len(tokens))
+	for i, token := range tokens {
+		out[i] = tokenView{Type: token.Type, Raw: token.Raw(), Start: token.Start, End: token.End}
+	}
+	return out
+}
+
 func SetUpSimpleJson() *Tokenizer {
 	text := "{\"msg\": \"hello\"}"
 	tk := NewTokenizer(text)
@@ -69,94 +96,944 @@ func TestTokenizer_ConsumeWhiteSpace(t *testing.T) {
 func TestTokenizer_Tokenize(t *testing.T) {
 	var tests = []struct {
 		json string
-		want []Token
+		want []tokenView
 	}{
-		{"[true]", []Token{
+		{"[true]", []tokenView{
 			{
-				Type:     TLSquareBracket,
-				Data:     []rune{'['},
-				StartPos: 0,
-				EndPos:   1,
+				Type:  TLSquareBracket,
+				Raw:   []byte("["),
+				Start: 0,
+				End:   1,
 			},
 			{
-				Type:     TTrue,
-				Data:     []rune("true"),
-				StartPos: 1,
-				EndPos:   5,
+				Type:  TTrue,
+				Raw:   []byte("true"),
+				Start: 1,
+				End:   5,
 			},
 			{
-				Type:     TRSquareBracket,
-				Data:     []rune{']'},
-				StartPos: 5,
-				EndPos:   6,
+				Type:  TRSquareBracket,
+				Raw:   []byte("]"),
+				Start: 5,
+				End:   6,
 			},
 			{
-				Type:     TEof,
-				Data:     []rune{},
-				StartPos: 6,
-				EndPos:   7,
+				Type:  TEof,
+				Raw:   []byte{},
+				Start: 6,
+				End:   6,
 			},
 		}},
 		{
-			"\"hello\"", []Token{
+			"\"hello\"", []tokenView{
 				{
-					Type:     TString,
-					Data:     []rune("hello"),
-					StartPos: 0,
-					EndPos:   7,
+					Type:  TString,
+					Raw:   []byte("hello"),
+					Start: 0,
+					End:   7,
 				},
 				{
-					Type:     TEof,
-					Data:     []rune{},
-					StartPos: 7,
-					EndPos:   8,
+					Type:  TEof,
+					Raw:   []byte{},
+					Start: 7,
+					End:   7,
 				},
 			},
 		},
 		{
-			"{\"msg\": \"hello\"}", []Token{
+			"{\"msg\": \"hello\"}", []tokenView{
 				{
-					Type:     TLCurlyBracket,
-					Data:     []rune("{"),
-					StartPos: 0,
-					EndPos:   1,
+					Type:  TLCurlyBracket,
+					Raw:   []byte("{"),
+					Start: 0,
+					End:   1,
 				},
 				{
-					Type:     TString,
-					Data:     []rune("msg"),
-					StartPos: 1,
-					EndPos:   6,
+					Type:  TString,
+					Raw:   []byte("msg"),
+					Start: 1,
+					End:   6,
 				},
 				{
-					Type:     TColon,
-					Data:     []rune(":"),
-					StartPos: 6,
-					EndPos:   7,
+					Type:  TColon,
+					Raw:   []byte(":"),
+					Start: 6,
+					End:   7,
 				},
 				{
-					Type:     TString,
-					Data:     []rune("hello"),
-					StartPos: 8,
-					EndPos:   15,
+					Type:  TString,
+					Raw:   []byte("hello"),
+					Start: 8,
+					End:   15,
 				},
 				{
-					Type:     TRCurlyBracket,
-					Data:     []rune("}"),
-					StartPos: 15,
-					EndPos:   16,
+					Type:  TRCurlyBracket,
+					Raw:   []byte("}"),
+					Start: 15,
+					End:   16,
 				},
 				{
-					Type:     TEof,
-					Data:     []rune{},
-					StartPos: 16,
-					EndPos:   17,
+					Type:  TEof,
+					Raw:   []byte{},
+					Start: 16,
+					End:   16,
 				},
 			},
 		},
 	}
 
 	for _, tt := range tests {
-		tokens := NewTokenizer(tt.json).Tokenize()
-		assert.Equal(t, tt.want, *tokens)
+		tokens, err := NewTokenizer(tt.json).Tokenize()
+		if assert.NoError(t, err) {
+			assert.Equal(t, tt.want, views(*tokens))
+		}
+	}
+}
+
+func TestTokenizer_ConsumeString(t *testing.T) {
+	var tests = []struct {
+		json   string
+		policy LoneSurrogatePolicy
+		want   string
+		endPos int
+	}{
+		{`"hello"`, LoneSurrogateError, "hello", 7},
+		{`"a\"b"`, LoneSurrogateError, `a"b`, 6},
+		{`"\\"`, LoneSurrogateError, `\`, 4},
+		{`"\\\""`, LoneSurrogateError, `\"`, 6},
+		{`"\/\b\f\n\r\t"`, LoneSurrogateError, "/\b\f\n\r\t", 14},
+		{`"\u00e9t\u00C9"`, LoneSurrogateError, "\u00e9t\u00c9", 15},
+		{`"\ud83d\ude00"`, LoneSurrogateError, "\U0001F600", 14},
+		{`"\ud83d"`, LoneSurrogateReplace, "\ufffd", 8},
+		{`"\ude00\ud83d"`, LoneSurrogateReplace, "\ufffd\ufffd", 14},
+		{`"\ud83dx"`, LoneSurrogateReplace, "\ufffdx", 9},
+		{`"\ud83d\u0041"`, LoneSurrogateReplace, "\ufffdA", 14},
+		{`"\ud83d"`, LoneSurrogateKeep, "\xed\xa0\xbd", 8},
+	}
+
+	for _, tt := range tests {
+		tk := NewTokenizer(tt.json)
+		tk.Options.LoneSurrogates = tt.policy
+		token, err := tk.ConsumeString()
+		if assert.NoError(t, err, tt.json) {
+			assert.Equal(t, tt.want, token.MustLoadAsString(), tt.json)
+			assert.Equal(t, tt.endPos, token.End, tt.json)
+		}
+	}
+}
+
+func TestTokenizer_ConsumeString_InvalidEscape(t *testing.T) {
+	var tests = []struct {
+		json     string
+		startPos int
+		endPos   int
+	}{
+		{`"\x"`, 1, 3},
+		{`"ab\'"`, 3, 5},
+		{`"\u12"`, 1, 5},
+		{`"\u12G4"`, 1, 5},
+		{`"\`, 1, 2},
+		{`"a\ud83d"`, 2, 8},
+		{`"\ud83d\u12"`, 1, 7},
+		{`"\ude00"`, 1, 7},
+	}
+
+	for _, tt := range tests {
+		_, err := NewTokenizer(tt.json).ConsumeString()
+		if assert.Error(t, err, tt.json) {
+			tkErr := err.(*TokenizerError)
+			assert.Equal(t, InvalidEscapeError, tkErr.ErrorType, tt.json)
+			assert.Equal(t, tt.startPos, tkErr.Start.Offset, tt.json)
+			assert.Equal(t, tt.endPos, tkErr.End.Offset, tt.json)
+		}
+	}
+}
+
+func TestTokenizer_ConsumeNumber(t *testing.T) {
+	var tests = []struct {
+		json string
+		want float64
+	}{
+		{"0", 0},
+		{"-0", 0},
+		{"123", 123},
+		{"-123", -123},
+		{"0.5", 0.5},
+		{"-12.25", -12.25},
+		{"1e10", 1e10},
+		{"6.02E23", 6.02e23},
+		{"1.5e-3", 1.5e-3},
+		{"2E+2", 200},
+		{"1e400", math.Inf(1)},
+		{"-1e400", math.Inf(-1)},
+		{"1e-400", 0},
+	}
+
+	for _, tt := range tests {
+		token, err := NewTokenizer(tt.json).ConsumeNumber()
+		if assert.NoError(t, err, tt.json) {
+			assert.Equal(t, TNumber, token.Type, tt.json)
+			assert.Equal(t, len([]rune(tt.json)), token.End, tt.json)
+			assert.Equal(t, tt.want, token.MustLoadAsFloat64(), tt.json)
+		}
+	}
+}
+
+func TestTokenizer_ConsumeNumber_Invalid(t *testing.T) {
+	var tests = []struct {
+		json string
+		msg  string
+	}{
+		{"+5", "Plus sign is not allowed at the beginning."},
+		{"01", "Leading zeros are not allowed."},
+		{"-007", "Leading zeros are not allowed."},
+		{"1.", "Dot must be followed by a digit."},
+		{"1.e5", "Dot must be followed by a digit."},
+		{"-.5", "Dot must be preceded by a digit."},
+		{".5", "Dot must be preceded by a digit."},
+		{"-", "Minus must be followed by a digit."},
+		{"--1", "Minus must be followed by a digit."},
+		{"1e", "Exponent must contain at least one digit."},
+		{"1e+", "Exponent must contain at least one digit."},
+		{"1.2.3", "There must not be more than one dot."},
+	}
+
+	for _, tt := range tests {
+		_, err := NewTokenizer(tt.json).ConsumeNumber()
+		if assert.Error(t, err, tt.json) {
+			tkErr := err.(*TokenizerError)
+			assert.Equal(t, InvalidDataError, tkErr.ErrorType, tt.json)
+			assert.Equal(t, tt.msg, tkErr.ErrorMessage, tt.json)
+			assert.Equal(t, 0, tkErr.Start.Offset, tt.json)
+			assert.Equal(t, len([]rune(tt.json)), tkErr.End.Offset, tt.json)
+		}
+	}
+}
+
+func TestTokenizer_ConsumeNumber_JSON5(t *testing.T) {
+	var tests = []struct {
+		json string
+		want float64
+	}{
+		{"0xdecaf", 0xdecaf},
+		{"-0XFF", -0xff},
+		{"+1", 1},
+		{".8675309", .8675309},
+		{"8675309.", 8675309},
+		{"-.5e2", -50},
+		{"Infinity", math.Inf(1)},
+		{"+Infinity", math.Inf(1)},
+		{"-Infinity", math.Inf(-1)},
+	}
+
+	for _, tt := range tests {
+		tk := NewTokenizer(tt.json)
+		tk.Options.Dialect = DialectJSON5
+		token, err := tk.Next()
+		if assert.NoError(t, err, tt.json) {
+			assert.Equal(t, TNumber, token.Type, tt.json)
+			assert.Equal(t, len(tt.json), token.End, tt.json)
+			assert.Equal(t, tt.want, token.MustLoadAsFloat64(), tt.json)
+		}
+	}
+
+	tk := NewTokenizer("NaN")
+	tk.Options.Dialect = DialectJSON5
+	token, err := tk.Next()
+	assert.NoError(t, err)
+	assert.True(t, math.IsNaN(token.MustLoadAsFloat64()))
+
+	var invalid = []struct {
+		json string
+		msg  string
+	}{
+		{"0x", "Hexadecimal number must contain at least one digit."},
+		{"01", "Leading zeros are not allowed."},
+		{".", "Number must contain at least one digit."},
+		{"+", "Number must contain at least one digit."},
+		{"1e", "Exponent must contain at least one digit."},
+		{"1.2.3", "There must not be more than one dot."},
+	}
+
+	for _, tt := range invalid {
+		tk := NewTokenizer(tt.json)
+		tk.Options.Dialect = DialectJSON5
+		_, err := tk.ConsumeNumber()
+		if assert.Error(t, err, tt.json) {
+			assert.Equal(t, tt.msg, err.(*TokenizerError).ErrorMessage, tt.json)
+		}
+	}
+}
+
+func TestTokenizer_ConsumeString_JSON5(t *testing.T) {
+	var tests = []struct {
+		json string
+		want string
+	}{
+		{`'single'`, "single"},
+		{`'I can use "double quotes" here'`, `I can use "double quotes" here`},
+		{`"it\'s"`, "it's"},
+		{`'\x41\v\0'`, "A\v\x00"},
+		{`'\q\u00e9'`, "q\u00e9"},
+		{"\"Look, Mom! \\\nNo \\\\n's!\"", "Look, Mom! No \\n's!"},
+		{"'a\\\r\nb\\\rc\\\u2028d'", "abcd"},
+		{"'tab\tinside'", "tab\tinside"},
+	}
+
+	for _, tt := range tests {
+		tk := NewTokenizer(tt.json)
+		tk.Options.Dialect = DialectJSON5
+		token, err := tk.Next()
+		if assert.NoError(t, err, tt.json) {
+			assert.Equal(t, TString, token.Type, tt.json)
+			assert.Equal(t, tt.want, token.MustLoadAsString(), tt.json)
+		}
+	}
+
+	var invalid = []struct {
+		json string
+		msg  string
+	}{
+		{`'\01'`, "octal escape sequences are not allowed"},
+		{`'\7'`, "octal escape sequences are not allowed"},
+		{`'\x4'`, "invalid hex escape sequence"},
+		{"'line\nbreak'", "control character U+000A must be escaped"},
+	}
+
+	for _, tt := range invalid {
+		tk := NewTokenizer(tt.json)
+		tk.Options.Dialect = DialectJSON5
+		_, err := tk.Next()
+		if assert.Error(t, err, tt.json) {
+			assert.Equal(t, tt.msg, err.(*TokenizerError).ErrorMessage, tt.json)
+		}
+	}
+
+	// strict JSON knows neither single quotes nor the extra escapes
+	_, err := NewTokenizer(`'single'`).Next()
+	assert.Error(t, err)
+	_, err = NewTokenizer(`"\x41"`).Next()
+	assert.Error(t, err)
+}
+
+func TestTokenizer_ConsumeKeyword_JSON5(t *testing.T) {
+	var tests = []struct {
+		json string
+		typ  TokenType
+		want string
+	}{
+		{"unquoted", TIdentifier, "unquoted"},
+		{"$_a1", TIdentifier, "$_a1"},
+		{"ünïcödé", TIdentifier, "ünïcödé"},
+		{"\\u0061b", TIdentifier, "ab"},
+		{"true", TTrue, "true"},
+		{"null", TNull, "null"},
+		{"NaN", TNumber, "NaN"},
+	}
+
+	for _, tt := range tests {
+		tk := NewTokenizer(tt.json + ":")
+		tk.Options.Dialect = DialectJSON5
+		token, err := tk.Next()
+		if assert.NoError(t, err, tt.json) {
+			assert.Equal(t, tt.typ, token.Type, tt.json)
+			assert.Equal(t, len(tt.json), token.End, tt.json)
+			if tt.typ == TIdentifier {
+				assert.Equal(t, tt.want, token.MustLoadAsString(), tt.json)
+			}
+		}
+	}
+
+	tk := NewTokenizer("\\u0031a")
+	tk.Options.Dialect = DialectJSON5
+	_, err := tk.Next()
+	if assert.Error(t, err) {
+		assert.Equal(t, InvalidEscapeError, err.(*TokenizerError).ErrorType)
+	}
+}
+
+func TestTokenizer_ConsumeString_Unterminated(t *testing.T) {
+	var tests = []string{
+		"{\"msg\": \"hello",
+		"{\"msg\": \"hello\\\"}",
+		"{\"msg\": \"",
+	}
+
+	for _, json := range tests {
+		_, err := NewTokenizer(json).Tokenize()
+		var tkErr *TokenizerError
+		if assert.ErrorAs(t, err, &tkErr, json) {
+			assert.Equal(t, UnterminatedStringError, tkErr.ErrorType, json)
+			assert.Equal(t, linePos(8), tkErr.Start, json)
+			assert.Equal(t, linePos(9), tkErr.End, json)
+		}
+	}
+}
+
+func TestTokenizer_ConsumeString_ControlCharacter(t *testing.T) {
+	var tests = []struct {
+		json  string
+		start Position
+		msg   string
+	}{
+		{"\"a\nb\"", linePos(2), "control character U+000A must be escaped"},
+		{"\"a\tb\"", linePos(2), "control character U+0009 must be escaped"},
+		{"\"ab\x00\"", linePos(3), "control character U+0000 must be escaped"},
+		{"\"\x1f\r\n\"", linePos(1), "control character U+001F must be escaped"},
+	}
+
+	for _, tt := range tests {
+		tk := NewTokenizer(tt.json)
+		token, err := tk.ConsumeString()
+		var tkErr *TokenizerError
+		if assert.ErrorAs(t, err, &tkErr, tt.json) {
+			assert.Equal(t, UnexpectedCharacterError, tkErr.ErrorType, tt.json)
+			assert.Equal(t, tt.msg, tkErr.ErrorMessage, tt.json)
+			assert.Equal(t, tt.start, tkErr.Start, tt.json)
+		}
+		// the whole string is consumed nevertheless
+		assert.Equal(t, len(tt.json), token.End, tt.json)
+	}
+
+	token, err := NewTokenizer("\"\x7f\"").ConsumeString()
+	assert.NoError(t, err)
+	assert.Equal(t, "\x7f", token.MustLoadAsString())
+}
+
+func TestTokenizer_ConsumeComment(t *testing.T) {
+	tk := NewTokenizer("// head\r\n{/* a */\"a\": 1 // tail\n}")
+	tk.Options.Comments = true
+	tokens, err := tk.Tokenize()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var comments []string
+	for _, token := range *tokens {
+		if token.Type == TComment {
+			comments = append(comments, string(token.Raw()))
+		}
+	}
+	assert.Equal(t, []string{"// head", "/* a */", "// tail"}, comments)
+
+	// a comment right behind a keyword ends it
+	for _, json := range []string{"[true/*c*/]", "{\"a\": null// x\n}"} {
+		tk := NewTokenizer(json)
+		tk.Options.Comments = true
+		_, err := tk.Tokenize()
+		assert.NoError(t, err, json)
+	}
+
+	var tests = []struct {
+		json      string
+		errorType ErrorType
+		start     Position
+	}{
+		{"[1] /* open", UnterminatedCommentError, linePos(4)},
+		{"[1] / 2", UnexpectedCharacterError, linePos(4)},
+	}
+	for _, tt := range tests {
+		tk := NewTokenizer(tt.json)
+		tk.Options.Comments = true
+		_, err := tk.Tokenize()
+		var tkErr *TokenizerError
+		if assert.ErrorAs(t, err, &tkErr, tt.json) {
+			assert.Equal(t, tt.errorType, tkErr.ErrorType, tt.json)
+			assert.Equal(t, tt.start, tkErr.Start, tt.json)
+		}
+	}
+
+	// comments are not JSON
+	_, err = NewTokenizer("[1] // no").Tokenize()
+	assert.Error(t, err)
+}
+
+func TestTokenizer_Tokenize_Error(t *testing.T) {
+	var tests = []struct {
+		json      string
+		errorType ErrorType
+		startPos  int
+	}{
+		{"[1, tru]", UndefinedKeywordError, 4},
+		{"[01]", InvalidDataError, 1},
+		{"{\"a\\q\": 1}", InvalidEscapeError, 3},
+		{"[1 / 2]", UnexpectedCharacterError, 3},
+	}
+
+	for _, tt := range tests {
+		_, err := NewTokenizer(tt.json).Tokenize()
+		var tkErr *TokenizerError
+		if assert.ErrorAs(t, err, &tkErr, tt.json) {
+			assert.Equal(t, tt.errorType, tkErr.ErrorType, tt.json)
+			assert.Equal(t, tt.startPos, tkErr.Start.Offset, tt.json)
+		}
+		assert.Panics(t, func() { NewTokenizer(tt.json).MustTokenize() }, tt.json)
+	}
+}
+
+func TestTokenizer_TokenizeAll(t *testing.T) {
+	tokens, errs := NewTokenizer("[tru, 01, 2, #]").TokenizeAll()
+
+	var types []TokenType
+	for _, token := range *tokens {
+		types = append(types, token.Type)
+	}
+	assert.Equal(t, []TokenType{
+		TLSquareBracket, TUnknown, TComma, TUnknown, TComma, TNumber, TComma, TUnknown, TRSquareBracket, TEof,
+	}, types)
+
+	var errorTypes []ErrorType
+	for _, err := range errs {
+		errorTypes = append(errorTypes, err.ErrorType)
+	}
+	assert.Equal(t, []ErrorType{UndefinedKeywordError, InvalidDataError, UnexpectedCharacterError}, errorTypes)
+
+	// the rest of a string with an invalid escape is skipped
+	tokens, errs = NewTokenizer(`["a\q, b", "c"]`).TokenizeAll()
+	types = nil
+	for _, token := range *tokens {
+		types = append(types, token.Type)
+	}
+	assert.Equal(t, []TokenType{TLSquareBracket, TUnknown, TComma, TString, TRSquareBracket, TEof}, types)
+	assert.Len(t, errs, 1)
+
+	// but a JSON5 identifier with one is not a string
+	tk := NewTokenizer(`{ab\x: 1, "c": 2}`)
+	tk.Options.Dialect = DialectJSON5
+	tokens, errs = tk.TokenizeAll()
+	types = nil
+	for _, token := range *tokens {
+		types = append(types, token.Type)
+	}
+	assert.Equal(t, []TokenType{
+		TLCurlyBracket, TUnknown, TColon, TNumber, TComma, TString, TColon, TNumber, TRCurlyBracket, TEof,
+	}, types)
+	assert.Len(t, errs, 1)
+}
+
+func TestTokenizer_Limits(t *testing.T) {
+	var tests = []struct {
+		json    string
+		options TokenizerOptions
+		msg     string
+		offset  int
+	}{
+		{"[1, 2, 3, 4]", TokenizerOptions{MaxInputSize: 10}, "input exceeds the limit of 10 bytes", 10},
+		{"[\"hello\", \"hello!\"]", TokenizerOptions{MaxStringLength: 5}, "string exceeds the limit of 5 bytes", 10},
+		{"[\"\\u00e9\"]", TokenizerOptions{MaxStringLength: 5}, "string exceeds the limit of 5 bytes", 1},
+		{"[123, -1234]", TokenizerOptions{MaxNumberLength: 4}, "number exceeds the limit of 4 bytes", 6},
+		{"[1e1000]", TokenizerOptions{MaxNumberLength: 4}, "number exceeds the limit of 4 bytes", 1},
+	}
+
+	for _, tt := range tests {
+		tk := NewTokenizer(tt.json)
+		tk.Options = tt.options
+		_, err := tk.Tokenize()
+		if assert.Error(t, err, tt.json) {
+			tkErr := err.(*TokenizerError)
+			assert.Equal(t, LimitError, tkErr.ErrorType, tt.json)
+			assert.Equal(t, tt.msg, tkErr.ErrorMessage, tt.json)
+			assert.Equal(t, tt.offset, tkErr.Start.Offset, tt.json)
+		}
+	}
+
+	// right at the limits
+	tk := NewTokenizer("[\"hello\", 1234]")
+	tk.Options = TokenizerOptions{MaxInputSize: 15, MaxStringLength: 5, MaxNumberLength: 4}
+	_, err := tk.Tokenize()
+	assert.NoError(t, err)
+
+	// the input ends at MaxInputSize
+	tk = NewTokenizer("[1, 2, 3, 4]")
+	tk.Options.MaxInputSize = 10
+	tokens, errs := tk.TokenizeAll()
+	assert.Len(t, errs, 1)
+	if assert.Len(t, *tokens, 2) {
+		assert.Equal(t, TEof, (*tokens)[1].Type)
+		assert.Equal(t, 10, (*tokens)[1].Start)
+	}
+}
+
+func TestToken_Raw(t *testing.T) {
+	var tests = []struct {
+		json string
+		want string
+	}{
+		{`"hello"`, "hello"},
+		{`'hello'`, "hello"},
+		{`"a\\"`, `a\\`},
+		{`"a\""`, `a\"`},
+		// not closed, or stopped at an invalid escape
+		{`"abc\"`, `abc\"`},
+		{`"`, ""},
+		{`"\x" `, `\x`},
+		{`true`, "true"},
+	}
+	for _, tt := range tests {
+		tk := NewTokenizer(tt.json)
+		tk.Options.Dialect = DialectJSON5
+		token, _ := tk.Next()
+		assert.Equal(t, tt.want, string(token.Raw()), tt.json)
+	}
+
+	assert.Equal(t, "12", string(NewToken(TNumber, "12", 3, 5).Raw()))
+	assert.Nil(t, Token{}.Raw())
+}
+
+func TestToken_LoadAs(t *testing.T) {
+	str := NewToken(TString, "hello", 0, 7)
+	num := NewToken(TNumber, "12", 0, 2)
+
+	s, err := str.LoadAsString()
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", s)
+
+	_, err = str.LoadAsFloat64()
+	assert.Error(t, err)
+	_, err = num.LoadAsString()
+	assert.Error(t, err)
+	_, err = num.LoadAsBoolean()
+	assert.Error(t, err)
+	_, err = num.LoadAsNull()
+	assert.Error(t, err)
+
+	assert.Panics(t, func() { num.MustLoadAsString() })
+	assert.Equal(t, float64(12), num.MustLoadAsFloat64())
+	assert.Equal(t, true, NewToken(TTrue, "true", 0, 4).MustLoadAsBoolean())
+	assert.Equal(t, false, NewToken(TFalse, "false", 0, 5).MustLoadAsBoolean())
+	assert.Nil(t, NewToken(TNull, "null", 0, 4).MustLoadAsNull())
+}
+
+func TestNewTokenizerBytes(t *testing.T) {
+	src := []byte("{\"名前\": \"a\\u00e9\\n\"}")
+	tokens, err := NewTokenizerBytes(src).Tokenize()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	key := (*tokens)[1]
+	assert.Equal(t, TString, key.Type)
+	assert.Equal(t, 1, key.Start)
+	assert.Equal(t, 9, key.End)
+	assert.Equal(t, "名前", key.MustLoadAsString())
+
+	value := (*tokens)[3]
+	assert.Equal(t, 11, value.Start)
+	assert.Equal(t, 22, value.End)
+	assert.Equal(t, []byte("a\\u00e9\\n"), value.Raw())
+	assert.Equal(t, "a\u00e9\n", value.MustLoadAsString())
+
+	// tokens refer to the source instead of copying it
+	src[2] = 'x'
+	assert.Equal(t, byte('x'), key.Raw()[0])
+}
+
+// encode encodes text in enc, starting with a byte order mark if bom is set.
+func encode(text string, enc Encoding, bom bool) []byte {
+	if bom {
+		text = "\uFEFF" + text
+	}
+	var order binary.ByteOrder = binary.BigEndian
+	if enc == EncodingUTF16LE || enc == EncodingUTF32LE {
+		order = binary.LittleEndian
+	}
+
+	var b []byte
+	switch enc {
+	case EncodingUTF16BE, EncodingUTF16LE:
+		for _, u := range utf16.Encode([]rune(text)) {
+			b = append(b, 0, 0)
+			order.PutUint16(b[len(b)-2:], u)
+		}
+	case EncodingUTF32BE, EncodingUTF32LE:
+		for _, r := range text {
+			b = append(b, 0, 0, 0, 0)
+			order.PutUint32(b[len(b)-4:], uint32(r))
+		}
+	default:
+		b = []byte(text)
+	}
+	return b
+}
+
+func TestDetectEncoding(t *testing.T) {
+	var tests = []struct {
+		head    []byte
+		enc     Encoding
+		bomSize int
+	}{
+		{[]byte("{\"a"), EncodingUTF8, 0},
+		{[]byte("\xEF\xBB\xBF{\"a"), EncodingUTF8, 3},
+		{[]byte("\x00{\x00\""), EncodingUTF16BE, 0},
+		{[]byte("{\x00\"\x00"), EncodingUTF16LE, 0},
+		{[]byte("\xFE\xFF\x00{"), EncodingUTF16BE, 2},
+		{[]byte("\xFF\xFE{\x00"), EncodingUTF16LE, 2},
+		{[]byte("\x00\x00\x00{"), EncodingUTF32BE, 0},
+		{[]byte("{\x00\x00\x00"), EncodingUTF32LE, 0},
+		{[]byte("\x00\x00\xFE\xFF"), EncodingUTF32BE, 4},
+		{[]byte("\xFF\xFE\x00\x00"), EncodingUTF32LE, 4},
+		{[]byte("1\x00"), EncodingUTF16LE, 0},
+		{[]byte("1"), EncodingUTF8, 0},
+		{nil, EncodingUTF8, 0},
+	}
+
+	for _, tt := range tests {
+		enc, bomSize := DetectEncoding(tt.head)
+		assert.Equal(t, tt.enc, enc, "%q", tt.head)
+		assert.Equal(t, tt.bomSize, bomSize, "%q", tt.head)
+	}
+}
+
+func TestNewTokenizerBytes_Encoding(t *testing.T) {
+	json := "{\"名前\": [\"😀\", 1]}"
+	want := NewTokenizer(json).MustTokenize()
+
+	for _, enc := range []Encoding{EncodingUTF8, EncodingUTF16BE, EncodingUTF16LE, EncodingUTF32BE, EncodingUTF32LE} {
+		for _, bom := range []bool{false, true} {
+			tk := NewTokenizerBytes(encode(json, enc, bom))
+			tokens, err := tk.Tokenize()
+			if assert.NoError(t, err, "%v bom=%v", enc, bom) {
+				assert.Equal(t, enc, tk.Encoding)
+				assert.Equal(t, views(*want), views(*tokens), "%v bom=%v", enc, bom)
+			}
+		}
+	}
+}
+
+func TestTokenizer_InvalidEncoding(t *testing.T) {
+	var tests = []struct {
+		src    []byte
+		msg    string
+		offset int
+	}{
+		{[]byte("{\"a\": \"x\xffy\"}"), "invalid UTF-8 byte 0xFF", 8},
+		{[]byte("[\"\xe5\x90\"]"), "invalid UTF-8 byte 0xE5", 2},
+		{[]byte("[1, \xc0\xaf]"), "invalid UTF-8 byte 0xC0", 4},
+		{[]byte("\xef\xbb\xbf[\x80]"), "invalid UTF-8 byte 0x80", 1},
+		{append(encode("[\"", EncodingUTF16LE, false), 0x00, 0xD8, '"', 0, ']', 0), "invalid UTF-16LE sequence", 2},
+		{append(encode("[", EncodingUTF32BE, false), 0, 0x11, 0, 0), "invalid UTF-32BE sequence", 1},
+		{append(encode("[1]", EncodingUTF16BE, false), 0), "invalid UTF-16BE sequence", 3},
+	}
+
+	for _, tt := range tests {
+		_, err := NewTokenizerBytes(tt.src).Tokenize()
+		if assert.Error(t, err, "%q", tt.src) {
+			tkErr := err.(*TokenizerError)
+			assert.Equal(t, InvalidEncodingError, tkErr.ErrorType, "%q", tt.src)
+			assert.Equal(t, tt.msg, tkErr.ErrorMessage, "%q", tt.src)
+			assert.Equal(t, tt.offset, tkErr.Start.Offset, "%q", tt.src)
+		}
+	}
+
+	tk := NewTokenizerBytes([]byte("// caf\xe9\n[1]"))
+	tk.Options.Comments = true
+	_, err := tk.Tokenize()
+	if assert.Error(t, err) {
+		assert.Equal(t, 6, err.(*TokenizerError).Start.Offset)
+	}
+
+	// the rest of the input is still tokenized
+	tokens, errs := NewTokenizerBytes([]byte("[\xff, \"\xfe\", 1]")).TokenizeAll()
+	var types []TokenType
+	for _, token := range *tokens {
+		types = append(types, token.Type)
+	}
+	assert.Equal(t, []TokenType{TLSquareBracket, TUnknown, TComma, TUnknown, TComma, TNumber, TRSquareBracket, TEof}, types)
+	assert.Len(t, errs, 2)
+}
+
+func TestTokenizer_Position(t *testing.T) {
+	tk := NewTokenizer("{\n  \"名前\": 1,\r\n\t\"b\": [true]\n}")
+	tokens := tk.MustTokenize()
+
+	var tests = []struct {
+		index int
+		start Position
+		end   Position
+	}{
+		{0, Position{Offset: 0, Rune: 0, Line: 1, Column: 1}, Position{Offset: 1, Rune: 1, Line: 1, Column: 2}},
+		{1, Position{Offset: 4, Rune: 4, Line: 2, Column: 3}, Position{Offset: 12, Rune: 8, Line: 2, Column: 7}},
+		{3, Position{Offset: 14, Rune: 10, Line: 2, Column: 9}, Position{Offset: 15, Rune: 11, Line: 2, Column: 10}},
+		{5, Position{Offset: 19, Rune: 15, Line: 3, Column: 2}, Position{Offset: 22, Rune: 18, Line: 3, Column: 5}},
+		{11, Position{Offset: 32, Rune: 28, Line: 4, Column: 2}, Position{Offset: 32, Rune: 28, Line: 4, Column: 2}},
+	}
+
+	for _, tt := range tests {
+		token := (*tokens)[tt.index]
+		assert.Equal(t, tt.start, tk.Position(token.Start), string(token.Raw()))
+		assert.Equal(t, tt.end, tk.Position(token.End), string(token.Raw()))
+	}
+}
+
+func TestTokenizerError_Error(t *testing.T) {
+	tk := NewTokenizer("{\n  \"a\": 01\n}")
+	_, err := tk.Tokenize()
+	assert.EqualError(t, err, "[t-InvalidDataError @ 2:8] Leading zeros are not allowed.: `01`")
+
+	tk = NewTokenizer("{\n  \"a\": 01\n}")
+	tk.SourceName = "config.json"
+	_, err = tk.Tokenize()
+	assert.EqualError(t, err, "config.json:2:8: [t-InvalidDataError] Leading zeros are not allowed.: `01`")
+}
+
+func benchmarkJson() string {
+	var sb strings.Builder
+	sb.WriteString("[")
+	for i := 0; i < 5000; i++ {
+		if i > 0 {
+			sb.WriteString(",\n")
+		}
+		sb.WriteString(`{"id": `)
+		sb.WriteString(strconv.Itoa(i))
+		sb.WriteString(`, "name": "useré \"quoted\" 名前", "score": -12.5e-3, "active": true, "tags": ["a", "b", null]}`)
+	}
+	sb.WriteString("]")
+	return sb.String()
+}
+
+func BenchmarkTokenizer_Tokenize(b *testing.B) {
+	json := benchmarkJson()
+	b.SetBytes(int64(len(json)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := NewTokenizer(json).Tokenize(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkTokenizer_Next(b *testing.B) {
+	json := []byte(benchmarkJson())
+	b.SetBytes(int64(len(json)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tk := NewTokenizerBytes(json)
+		for {
+			token, err := tk.Next()
+			if err != nil {
+				b.Fatal(err)
+			}
+			if token.Type == TEof {
+				break
+			}
+		}
+	}
+}
+
+// runeTokenizer is the tokenizer as it was before it read UTF-8 in place:
+// it copies the input into a []rune and each token into a []rune of its own.
+// It is kept to compare the allocations, it does not check what it reads.
+type runeTokenizer struct {
+	Letters []rune
+	Pos     int
+}
+
+type runeToken struct {
+	Type     TokenType
+	Data     []rune
+	StartPos int
+	EndPos   int
+}
+
+func (t *runeTokenizer) consume(typ TokenType, accept func(r rune) bool) runeToken {
+	var data []rune
+	startPos := t.Pos
+	for t.Pos < len(t.Letters) && accept(t.Letters[t.Pos]) {
+		data = append(data, t.Letters[t.Pos])
+		t.Pos++
+	}
+	return runeToken{Type: typ, Data: data, StartPos: startPos, EndPos: t.Pos}
+}
+
+func (t *runeTokenizer) consumeString() runeToken {
+	var data []rune
+	startPos := t.Pos
+	// consume opening '"'
+	t.Pos++
+	for t.Pos < len(t.Letters) {
+		r := t.Letters[t.Pos]
+		t.Pos++
+		if r == '"' && t.Letters[t.Pos-2] != '\\' {
+			break
+		}
+		data = append(data, r)
+	}
+	return runeToken{Type: TString, Data: data, StartPos: startPos, EndPos: t.Pos}
+}
+
+func (t *runeTokenizer) Tokenize() *[]runeToken {
+	var tokens []runeToken
+	for t.Pos < len(t.Letters) {
+		r := t.Letters[t.Pos]
+		switch {
+		case r == '"':
+			tokens = append(tokens, t.consumeString())
+		case unicode.IsDigit(r) || r == '-' || r == '+':
+			tokens = append(tokens, t.consume(TNumber, func(r rune) bool {
+				return unicode.IsDigit(r) || strings.ContainsRune(".-+eE", r)
+			}))
+		case unicode.IsLetter(r):
+			token := t.consume(TUnknown, func(r rune) bool {
+				return !unicode.IsSpace(r) && !strings.ContainsRune(":,]}", r)
+			})
+			switch string(token.Data) {
+			case "true":
+				token.Type = TTrue
+			case "false":
+				token.Type = TFalse
+			case "null":
+				token.Type = TNull
+			}
+			tokens = append(tokens, token)
+		case unicode.IsSpace(r):
+			t.consume(TWhiteSpace, unicode.IsSpace)
+		default:
+			var typ TokenType
+			switch r {
+			case ',':
+				typ = TComma
+			case ':':
+				typ = TColon
+			case '{':
+				typ = TLCurlyBracket
+			case '}':
+				typ = TRCurlyBracket
+			case '[':
+				typ = TLSquareBracket
+			case ']':
+				typ = TRSquareBracket
+			}
+			tokens = append(tokens, runeToken{Type: typ, Data: []rune{r}, StartPos: t.Pos, EndPos: t.Pos + 1})
+			t.Pos++
+		}
+	}
+	tokens = append(tokens, runeToken{Type: TEof, Data: []rune{}, StartPos: t.Pos, EndPos: t.Pos + 1})
+	return &tokens
+}
+
+func TestRuneTokenizer(t *testing.T) {
+	// the old tokenizer reads the benchmark input into the same tokens
+	json := benchmarkJson()
+	tk := NewTokenizer(json)
+	tokens := tk.MustTokenize()
+	old := (&runeTokenizer{Letters: []rune(json)}).Tokenize()
+	if assert.Equal(t, len(*tokens), len(*old)) {
+		for i, token := range *tokens {
+			assert.Equal(t, token.Type, (*old)[i].Type)
+			assert.Equal(t, string(token.Raw()), string((*old)[i].Data))
+			assert.Equal(t, tk.Position(token.Start).Rune, (*old)[i].StartPos)
+		}
+	}
+}
+
+func BenchmarkRuneTokenizer_Tokenize(b *testing.B) {
+	json := benchmarkJson()
+	b.SetBytes(int64(len(json)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		(&runeTokenizer{Letters: []rune(json)}).Tokenize()
 	}
 }